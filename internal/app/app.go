@@ -69,16 +69,42 @@ func Run(cfg *config.Config) {
 	log.Debug().Msg("Initializing repository...")
 	repo := repository.NewPostgresRepository(db)
 
+	log.Debug().Msg("Initializing enricher...")
+	enrich, err := newEnricher(cfg, db)
+	if err != nil {
+		log.Fatal().Err(err).Msg("Failed to initialize enricher")
+	}
+
 	log.Debug().Msg("Initializing usecase...")
-	uc := usecase.NewUsecase(repo)
+	uc := usecase.NewUsecase(repo, enrich, cfg.ENRICH.COUNTRY_ID, cfg.PURGE.RETENTION, usecase.BulkOptions{
+		MaxItems:    cfg.BULK.MAX_ITEMS,
+		Concurrency: cfg.BULK.CONCURRENCY,
+	})
+
+	log.Debug().Msg("Starting enrichment workers...")
+	worker := usecase.NewEnrichmentWorker(repo, enrich, usecase.WorkerOptions{
+		Workers:      cfg.ENRICH.WORKER.COUNT,
+		PollInterval: cfg.ENRICH.WORKER.POLL_INTERVAL,
+		Lease:        cfg.ENRICH.WORKER.LEASE,
+		MaxAttempts:  cfg.ENRICH.WORKER.MAX_ATTEMPTS,
+		RetryDelay:   cfg.ENRICH.WORKER.RETRY_DELAY,
+	})
+	workerCtx, stopWorkers := context.WithCancel(context.Background())
+	workersDone := make(chan struct{})
+	go func() {
+		worker.Run(workerCtx)
+		close(workersDone)
+	}()
 
 	log.Debug().Msg("Initializing server...")
 	e := echo.New()
+	e.HTTPErrorHandler = delivery.HTTPErrorHandler
 
 	logger := zerolog.New(os.Stdout).With().Timestamp().Logger()
 	e.Use(middleware.RequestLoggerWithConfig(middleware.RequestLoggerConfig{
-		LogURI:    true,
-		LogStatus: true,
+		LogURI:      true,
+		LogStatus:   true,
+		HandleError: true,
 		LogValuesFunc: func(c echo.Context, v middleware.RequestLoggerValues) error {
 			logger.Info().
 				Str("uri", c.Request().RequestURI).
@@ -91,7 +117,12 @@ func Run(cfg *config.Config) {
 	}))
 
 	log.Debug().Msg("Registering routes...")
-	deliveryHandler := delivery.NewDelivery(uc)
+	deliveryHandler := delivery.NewDelivery(uc, delivery.Options{
+		RequireIfMatch: cfg.HTTP.REQUIRE_IF_MATCH,
+		AdminToken:     cfg.HTTP.ADMIN_TOKEN,
+		ActorHeader:    cfg.HTTP.ACTOR_HEADER,
+		BulkBodyLimit:  cfg.BULK.BODY_LIMIT,
+	})
 	deliveryHandler.RegisterRoutes(e)
 
 	log.Info().Msg("Starting server...")
@@ -112,5 +143,9 @@ func Run(cfg *config.Config) {
 		e.Logger.Fatal(err)
 	}
 
+	log.Debug().Msg("Stopping enrichment workers...")
+	stopWorkers()
+	<-workersDone
+
 	log.Info().Msg("Server gracefully shutdown")
 }
@@ -0,0 +1,100 @@
+package app
+
+import (
+	"fmt"
+	"github.com/OksidGen/enrich_server/internal/config"
+	"github.com/OksidGen/enrich_server/internal/enricher"
+	"github.com/OksidGen/enrich_server/internal/repository"
+	"github.com/jmoiron/sqlx"
+)
+
+const (
+	offlineOff      = "off"
+	offlinePrimary  = "primary"
+	offlineFallback = "fallback"
+	offlineOnly     = "only"
+
+	slavicOff        = "off"
+	slavicOverride   = "override"
+	slavicCrossCheck = "crosscheck"
+)
+
+func newEnricher(cfg *config.Config, db *sqlx.DB) (*enricher.Chain, error) {
+	var remote []enricher.Provider
+	if cfg.ENRICH.OFFLINE.MODE != offlineOnly {
+		remote = []enricher.Provider{
+			withBreaker(enricher.NewAgify(providerOptions(cfg.ENRICH.AGIFY, cfg.ENRICH)), cfg.ENRICH.AGIFY),
+			withBreaker(enricher.NewGenderize(providerOptions(cfg.ENRICH.GENDERIZE, cfg.ENRICH)), cfg.ENRICH.GENDERIZE),
+			withBreaker(enricher.NewNationalize(providerOptions(cfg.ENRICH.NATIONALIZE, cfg.ENRICH)), cfg.ENRICH.NATIONALIZE),
+		}
+	}
+	if cfg.ENRICH.CACHE.SIZE > 0 || cfg.ENRICH.CACHE.PERSISTENT {
+		var store enricher.CacheStore
+		if cfg.ENRICH.CACHE.PERSISTENT {
+			store = repository.NewPostgresCacheRepository(db)
+		}
+		cache := enricher.NewCache(cfg.ENRICH.CACHE.SIZE, cfg.ENRICH.CACHE.TTL, store)
+		for i, provider := range remote {
+			remote[i] = enricher.Cached(provider, cache)
+		}
+	}
+
+	var offline []enricher.Provider
+	switch cfg.ENRICH.OFFLINE.MODE {
+	case offlineOff:
+	case offlinePrimary, offlineFallback, offlineOnly:
+		dataset, err := enricher.LoadDataset(cfg.ENRICH.OFFLINE.DATASET)
+		if err != nil {
+			return nil, err
+		}
+		offline = dataset.Providers()
+	default:
+		return nil, fmt.Errorf("invalid offline enrichment mode: %s", cfg.ENRICH.OFFLINE.MODE)
+	}
+
+	providers := append(remote, offline...)
+	if cfg.ENRICH.OFFLINE.MODE == offlinePrimary {
+		providers = append(offline, remote...)
+	}
+
+	switch cfg.ENRICH.SLAVIC_RULES {
+	case slavicOff:
+	case slavicOverride:
+		providers = append([]enricher.Provider{enricher.NewSlavicGender()}, providers...)
+	case slavicCrossCheck:
+		for i, provider := range providers {
+			if provider.Attribute() == enricher.AttributeGender {
+				providers[i] = enricher.CrossChecked(provider, enricher.NewSlavicGender())
+			}
+		}
+	default:
+		return nil, fmt.Errorf("invalid slavic rules mode: %s", cfg.ENRICH.SLAVIC_RULES)
+	}
+
+	return enricher.NewChain(cfg.ENRICH.DEADLINE, providers...), nil
+}
+
+func providerOptions(cfg config.PROVIDER, enrich config.ENRICH) enricher.Options {
+	apiKey := enrich.APIKEY
+	if cfg.APIKEY != "" {
+		apiKey = cfg.APIKEY
+	}
+	return enricher.Options{
+		URL:           cfg.URL,
+		APIKey:        apiKey,
+		Timeout:       cfg.TIMEOUT,
+		Retries:       cfg.RETRIES,
+		Backoff:       cfg.BACKOFF,
+		MaxBackoff:    cfg.MAX_BACKOFF,
+		BatchSize:     enrich.BATCH_SIZE,
+		BatchWindow:   enrich.BATCH_WINDOW,
+		BatchDeadline: enrich.DEADLINE,
+	}
+}
+
+func withBreaker(provider enricher.Provider, cfg config.PROVIDER) enricher.Provider {
+	if cfg.BREAKER_THRESHOLD <= 0 {
+		return provider
+	}
+	return enricher.WithBreaker(provider, cfg.BREAKER_THRESHOLD, cfg.BREAKER_COOLDOWN)
+}
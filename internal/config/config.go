@@ -5,11 +5,16 @@ import (
 	"github.com/caarlos0/env/v10"
 	"github.com/joho/godotenv"
 	"github.com/rs/zerolog/log"
+	"time"
 )
 
 type (
 	Config struct {
-		PG `envPrefix:"PG_"`
+		PG     `envPrefix:"PG_"`
+		HTTP   `envPrefix:"HTTP_"`
+		PURGE  `envPrefix:"PURGE_"`
+		BULK   `envPrefix:"BULK_"`
+		ENRICH `envPrefix:"ENRICH_"`
 	}
 
 	PG struct {
@@ -19,6 +24,67 @@ type (
 		PORT     int    `env:"PORT"`
 		DATABASE string `env:"DATABASE"`
 	}
+
+	HTTP struct {
+		REQUIRE_IF_MATCH bool   `env:"REQUIRE_IF_MATCH" envDefault:"false"`
+		ADMIN_TOKEN      string `env:"ADMIN_TOKEN"`
+		ACTOR_HEADER     string `env:"ACTOR_HEADER" envDefault:"X-User"`
+	}
+
+	PURGE struct {
+		RETENTION time.Duration `env:"RETENTION" envDefault:"720h"`
+	}
+
+	BULK struct {
+		MAX_ITEMS   int    `env:"MAX_ITEMS" envDefault:"1000"`
+		CONCURRENCY int    `env:"CONCURRENCY" envDefault:"8"`
+		BODY_LIMIT  string `env:"BODY_LIMIT" envDefault:"10M"`
+	}
+
+	ENRICH struct {
+		APIKEY       string        `env:"APIKEY"`
+		DEADLINE     time.Duration `env:"DEADLINE" envDefault:"10s"`
+		COUNTRY_ID   string        `env:"COUNTRY_ID"`
+		BATCH_SIZE   int           `env:"BATCH_SIZE" envDefault:"10"`
+		BATCH_WINDOW time.Duration `env:"BATCH_WINDOW" envDefault:"20ms"`
+		SLAVIC_RULES string        `env:"SLAVIC_RULES" envDefault:"override"`
+		AGIFY        PROVIDER      `envPrefix:"AGIFY_"`
+		GENDERIZE    PROVIDER      `envPrefix:"GENDERIZE_"`
+		NATIONALIZE  PROVIDER      `envPrefix:"NATIONALIZE_"`
+		WORKER       WORKER        `envPrefix:"WORKER_"`
+		CACHE        CACHE         `envPrefix:"CACHE_"`
+		OFFLINE      OFFLINE       `envPrefix:"OFFLINE_"`
+	}
+
+	PROVIDER struct {
+		URL               string        `env:"URL"`
+		APIKEY            string        `env:"APIKEY"`
+		TIMEOUT           time.Duration `env:"TIMEOUT" envDefault:"5s"`
+		RETRIES           int           `env:"RETRIES" envDefault:"2"`
+		BACKOFF           time.Duration `env:"BACKOFF" envDefault:"200ms"`
+		MAX_BACKOFF       time.Duration `env:"MAX_BACKOFF" envDefault:"5s"`
+		BREAKER_THRESHOLD int           `env:"BREAKER_THRESHOLD" envDefault:"5"`
+		BREAKER_COOLDOWN  time.Duration `env:"BREAKER_COOLDOWN" envDefault:"30s"`
+	}
+
+	WORKER struct {
+		COUNT         int           `env:"COUNT" envDefault:"4"`
+		POLL_INTERVAL time.Duration `env:"POLL_INTERVAL" envDefault:"1s"`
+		LEASE         time.Duration `env:"LEASE" envDefault:"1m"`
+		MAX_ATTEMPTS  int           `env:"MAX_ATTEMPTS" envDefault:"5"`
+		RETRY_DELAY   time.Duration `env:"RETRY_DELAY" envDefault:"30s"`
+	}
+
+	CACHE struct {
+		SIZE       int           `env:"SIZE" envDefault:"10000"`
+		TTL        time.Duration `env:"TTL" envDefault:"24h"`
+		PERSISTENT bool          `env:"PERSISTENT" envDefault:"false"`
+	}
+
+	OFFLINE struct {
+		MODE    string `env:"MODE" envDefault:"off"`
+		DATASET string `env:"DATASET"`
+	}
 )
 
 func NewConfig() (*Config, error) {
@@ -0,0 +1,90 @@
+package delivery
+
+import (
+	"errors"
+	"github.com/OksidGen/enrich_server/internal/entity"
+	"github.com/labstack/echo/v4"
+	"github.com/rs/zerolog/log"
+	"net/http"
+)
+
+const (
+	problemContentType = "application/problem+json"
+	serverErrorDetail  = "The server could not process the request"
+)
+
+// Problem is an RFC 7807 problem details body.
+type Problem struct {
+	Type       string             `json:"type"`
+	Title      string             `json:"title"`
+	Status     int                `json:"status"`
+	Detail     string             `json:"detail,omitempty"`
+	Instance   string             `json:"instance,omitempty"`
+	Violations []entity.Violation `json:"violations,omitempty"`
+}
+
+// problemFor maps a domain error to the problem describing it.
+func problemFor(err error, instance string) Problem {
+	problem := Problem{
+		Type:     "about:blank",
+		Status:   http.StatusInternalServerError,
+		Detail:   err.Error(),
+		Instance: instance,
+	}
+
+	var httpErr *echo.HTTPError
+	var verr *entity.ValidationError
+	switch {
+	case errors.As(err, &httpErr):
+		problem.Status = httpErr.Code
+		problem.Detail = http.StatusText(httpErr.Code)
+		if message, ok := httpErr.Message.(string); ok {
+			problem.Detail = message
+		}
+	case errors.As(err, &verr):
+		problem.Status = http.StatusBadRequest
+		problem.Violations = verr.Violations
+	case errors.Is(err, entity.ErrValidation):
+		problem.Status = http.StatusBadRequest
+	case errors.Is(err, entity.ErrNotFound):
+		problem.Status = http.StatusNotFound
+	case errors.Is(err, entity.ErrConflict):
+		problem.Status = http.StatusConflict
+	case errors.Is(err, entity.ErrPreconditionFailed):
+		problem.Status = http.StatusPreconditionFailed
+	case errors.Is(err, entity.ErrUpstreamUnavailable):
+		problem.Status = http.StatusServiceUnavailable
+	}
+	problem.Title = http.StatusText(problem.Status)
+	// Server errors carry database and driver messages that clients must not
+	// see. They are logged instead.
+	if problem.Status >= http.StatusInternalServerError && httpErr == nil {
+		problem.Detail = serverErrorDetail
+	}
+
+	return problem
+}
+
+// HTTPErrorHandler maps domain errors to HTTP statuses and writes them as
+// problem+json.
+func HTTPErrorHandler(err error, c echo.Context) {
+	if c.Response().Committed {
+		return
+	}
+
+	problem := problemFor(err, c.Request().URL.Path)
+
+	if problem.Status >= http.StatusInternalServerError {
+		log.Err(err).Str("path", problem.Instance).Msg("Request failed")
+	}
+
+	if c.Request().Method == http.MethodHead {
+		err = c.NoContent(problem.Status)
+	} else {
+		c.Response().Header().Set(echo.HeaderContentType, problemContentType)
+		err = c.JSON(problem.Status, problem)
+	}
+	if err != nil {
+		log.Err(err).Msg("Failed to write error response")
+	}
+}
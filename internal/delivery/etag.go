@@ -0,0 +1,85 @@
+package delivery
+
+import (
+	"fmt"
+	"github.com/labstack/echo/v4"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// anyVersion is the version passed to the usecase when If-Match imposes no
+// precondition.
+const anyVersion = 0
+
+func etag(version int) string {
+	return strconv.Quote(strconv.Itoa(version))
+}
+
+// parseETags splits an If-Match or If-None-Match header into entity tags.
+func parseETags(header string) []string {
+	var tags []string
+	for _, tag := range strings.Split(header, ",") {
+		if tag = strings.TrimSpace(tag); tag != "" {
+			tags = append(tags, tag)
+		}
+	}
+	return tags
+}
+
+// notModified reports whether If-None-Match matches version using weak
+// comparison.
+func notModified(c echo.Context, version int) bool {
+	for _, tag := range parseETags(c.Request().Header.Get("If-None-Match")) {
+		if tag == "*" || strings.TrimPrefix(tag, "W/") == etag(version) {
+			return true
+		}
+	}
+	return false
+}
+
+// ifMatchVersion returns the version required by If-Match and whether there
+// is such a precondition at all. A missing header and "*" impose none.
+// If-Match listing several tags is resolved against the current version of
+// the person. Versions start at 1, so a tag like "0" never matches.
+func (d *Delivery) ifMatchVersion(c echo.Context, id int) (int, bool, error) {
+	header := c.Request().Header.Get("If-Match")
+	if header == "" {
+		if d.opts.RequireIfMatch {
+			return 0, false, echo.NewHTTPError(http.StatusPreconditionRequired, "If-Match header is required")
+		}
+		return 0, false, nil
+	}
+
+	var versions []int
+	for _, tag := range parseETags(header) {
+		if tag == "*" {
+			return 0, false, nil
+		}
+		unquoted, err := strconv.Unquote(tag)
+		if err != nil {
+			continue
+		}
+		if version, err := strconv.Atoi(unquoted); err == nil && version > 0 {
+			versions = append(versions, version)
+		}
+	}
+
+	switch len(versions) {
+	case 0:
+		return 0, false, echo.NewHTTPError(http.StatusPreconditionFailed, fmt.Sprintf("If-Match %s does not match", header))
+	case 1:
+		return versions[0], true, nil
+	}
+
+	person, err := d.usecase.GetPersonByID(c.Request().Context(), id)
+	if err != nil {
+		return 0, false, err
+	}
+	for _, version := range versions {
+		if version == person.Version {
+			return version, true, nil
+		}
+	}
+	return 0, false, echo.NewHTTPError(http.StatusPreconditionFailed, fmt.Sprintf("If-Match %s does not match", header))
+}
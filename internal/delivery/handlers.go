@@ -1,30 +1,87 @@
 package delivery
 
 import (
-	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/OksidGen/enrich_server/internal/entity"
 	"github.com/OksidGen/enrich_server/internal/usecase"
 	"github.com/labstack/echo/v4"
+	"github.com/labstack/echo/v4/middleware"
 	"github.com/rs/zerolog/log"
+	"io"
+	"mime"
 	"net/http"
 	"strconv"
 )
 
+type Options struct {
+	RequireIfMatch bool
+	AdminToken     string
+	ActorHeader    string
+	// BulkBodyLimit caps the body of POST /people/batch, e.g. "10M".
+	BulkBodyLimit string
+}
+
 type Delivery struct {
 	usecase usecase.Usecase
+	opts    Options
 }
 
-func NewDelivery(usecase usecase.Usecase) *Delivery {
-	return &Delivery{usecase}
+func NewDelivery(usecase usecase.Usecase, opts Options) *Delivery {
+	return &Delivery{usecase, opts}
 }
 
 func (d *Delivery) RegisterRoutes(e *echo.Echo) {
+	e.Use(d.withActor)
+
 	e.GET("/", d.Root)
 	e.GET("/ping", d.Ping)
 	e.GET("/people", d.GetPeople)
 	e.GET("/people/:id", d.GetPerson)
+	e.GET("/people/:id/provenance", d.GetProvenance)
+	e.GET("/people/:id/history", d.GetHistory)
 	e.POST("/people", d.CreatePerson)
-	e.PUT("/people/:id", d.UpdatePerson)
+	var bulkMiddleware []echo.MiddlewareFunc
+	if d.opts.BulkBodyLimit != "" {
+		bulkMiddleware = append(bulkMiddleware, middleware.BodyLimit(d.opts.BulkBodyLimit))
+	}
+	e.POST("/people/batch", d.CreatePeople, bulkMiddleware...)
+	e.PUT("/people/:id", d.ReplacePerson)
+	e.PATCH("/people/:id", d.PatchPerson)
 	e.DELETE("/people/:id", d.DeletePerson)
+	e.POST("/people/:id/restore", d.RestorePerson)
+	e.POST("/people/:id/enrich", d.EnrichPerson)
+	e.POST("/people/enrich", d.EnrichPeople)
+	e.GET("/enrichment/status", d.GetEnrichmentStatus)
+
+	if d.opts.AdminToken == "" {
+		log.Warn().Msg("Admin token is not set, admin routes are disabled")
+		return
+	}
+	admin := e.Group("/admin")
+	admin.Use(middleware.KeyAuth(func(key string, c echo.Context) (bool, error) {
+		return subtle.ConstantTimeCompare([]byte(key), []byte(d.opts.AdminToken)) == 1, nil
+	}))
+	admin.POST("/purge", d.PurgePeople)
+}
+
+// withActor attributes changes made by the request to the user named in the
+// actor header.
+func (d *Delivery) withActor(next echo.HandlerFunc) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		if d.opts.ActorHeader == "" {
+			return next(c)
+		}
+		if actor := []rune(c.Request().Header.Get(d.opts.ActorHeader)); len(actor) != 0 {
+			if len(actor) > maxActorLength {
+				actor = actor[:maxActorLength]
+			}
+			c.SetRequest(c.Request().WithContext(entity.WithActor(c.Request().Context(), string(actor))))
+		}
+		return next(c)
+	}
 }
 
 func (d *Delivery) Root(c echo.Context) error {
@@ -47,10 +104,10 @@ func (d *Delivery) GetPeople(c echo.Context) error {
 		params[key] = value[0]
 	}
 
-	people, err := d.usecase.GetPeople(context.Background(), params)
+	people, err := d.usecase.GetPeople(c.Request().Context(), params)
 	if err != nil {
 		log.Error().Err(err).Msg("Failed to call usecase.GetPeople")
-		return c.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		return err
 	}
 	return c.JSON(http.StatusOK, people)
 }
@@ -58,58 +115,191 @@ func (d *Delivery) GetPeople(c echo.Context) error {
 func (d *Delivery) GetPerson(c echo.Context) error {
 	log.Debug().Msg("Calling GetPerson handler")
 
-	id, err := strconv.Atoi(c.Param("id"))
+	id, err := parseID(c)
 	if err != nil {
-		log.Error().Err(err).Msg("Failed to convert id to int")
-		return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+		return err
 	}
 
-	person, err := d.usecase.GetPersonByID(context.Background(), id)
+	var person entity.Person
+	if asOf := c.QueryParam("as_of"); asOf != "" {
+		person, err = d.usecase.GetPersonAsOf(c.Request().Context(), id, asOf)
+	} else {
+		person, err = d.usecase.GetPersonByID(c.Request().Context(), id)
+	}
 	if err != nil {
-		log.Err(err).Msg("Failed to call usecase.GetPersonByID")
-		return c.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		log.Err(err).Msg("Failed to get person")
+		return err
 	}
 
+	c.Response().Header().Set("ETag", etag(person.Version))
+	if notModified(c, person.Version) {
+		return c.NoContent(http.StatusNotModified)
+	}
 	return c.JSON(http.StatusOK, person)
 }
 
+func (d *Delivery) GetProvenance(c echo.Context) error {
+	log.Debug().Msg("Calling GetProvenance handler")
+
+	id, err := parseID(c)
+	if err != nil {
+		return err
+	}
+
+	provenance, err := d.usecase.GetProvenance(c.Request().Context(), id)
+	if err != nil {
+		log.Err(err).Msg("Failed to call usecase.GetProvenance")
+		return err
+	}
+
+	return c.JSON(http.StatusOK, provenance)
+}
+
+func (d *Delivery) GetHistory(c echo.Context) error {
+	log.Debug().Msg("Calling GetHistory handler")
+
+	id, err := parseID(c)
+	if err != nil {
+		return err
+	}
+
+	history, err := d.usecase.GetHistory(c.Request().Context(), id)
+	if err != nil {
+		log.Err(err).Msg("Failed to call usecase.GetHistory")
+		return err
+	}
+
+	return c.JSON(http.StatusOK, history)
+}
+
 func (d *Delivery) CreatePerson(c echo.Context) error {
 	log.Debug().Msg("Calling CreatePerson handler")
 
-	params := make(map[string]interface{})
-	if err := c.Bind(&params); err != nil {
-		log.Error().Err(err).Msg("Failed to bind params")
-		return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+	var req entity.PersonRequest
+	if err := entity.DecodePersonRequest(c.Request().Body, &req); err != nil {
+		log.Error().Err(err).Msg("Failed to bind person request")
+		return err
 	}
 
-	id, err := d.usecase.CreatePerson(context.Background(), params)
+	id, err := d.usecase.CreatePerson(c.Request().Context(), req)
 	if err != nil {
 		log.Err(err).Msg("Failed to call usecase.CreatePerson")
-		return c.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		return err
 	}
 
 	return c.JSON(http.StatusCreated, map[string]int{"id": id})
 }
 
-func (d *Delivery) UpdatePerson(c echo.Context) error {
-	log.Debug().Msg("Calling UpdatePerson handler")
-	id, err := strconv.Atoi(c.Param("id"))
+// BatchItem is the outcome of one item of POST /people/batch.
+type BatchItem struct {
+	Index  int      `json:"index"`
+	Status int      `json:"status"`
+	ID     int      `json:"id,omitempty"`
+	Error  *Problem `json:"error,omitempty"`
+}
+
+func (d *Delivery) CreatePeople(c echo.Context) error {
+	log.Debug().Msg("Calling CreatePeople handler")
+
+	var items []json.RawMessage
+	if err := json.NewDecoder(c.Request().Body).Decode(&items); err != nil {
+		log.Error().Err(err).Msg("Failed to bind batch request")
+		if errors.Is(err, echo.ErrStatusRequestEntityTooLarge) {
+			return err
+		}
+		return fmt.Errorf("%w: request body must be a JSON array of people", entity.ErrValidation)
+	}
+
+	results, err := d.usecase.CreatePeople(c.Request().Context(), items)
 	if err != nil {
-		log.Err(err).Msg("Failed to convert id to int")
-		return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+		log.Err(err).Msg("Failed to call usecase.CreatePeople")
+		return err
+	}
+
+	status := http.StatusCreated
+	response := make([]BatchItem, len(results))
+	for i, result := range results {
+		response[i] = BatchItem{Index: i, Status: http.StatusCreated, ID: result.ID}
+		if result.Err != nil {
+			problem := problemFor(result.Err, fmt.Sprintf("%s#/%d", c.Request().URL.Path, i))
+			response[i] = BatchItem{Index: i, Status: problem.Status, Error: &problem}
+			status = http.StatusMultiStatus
+		}
+	}
+
+	return c.JSON(status, response)
+}
+
+func (d *Delivery) ReplacePerson(c echo.Context) error {
+	log.Debug().Msg("Calling ReplacePerson handler")
+	id, err := parseID(c)
+	if err != nil {
+		return err
+	}
+
+	version, matched, err := d.ifMatchVersion(c, id)
+	if err != nil {
+		return err
+	}
+	if !matched {
+		version = anyVersion
 	}
 
-	updates := make(map[string]interface{})
-	if err := c.Bind(&updates); err != nil {
-		log.Err(err).Msg("Failed to bind updates")
-		return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+	var req entity.PersonRequest
+	if err := entity.DecodePersonReplacement(c.Request().Body, &req); err != nil {
+		log.Err(err).Msg("Failed to bind person request")
+		return err
 	}
-	delete(updates, "id")
 
-	err = d.usecase.UpdatePerson(context.Background(), id, updates)
+	enrich, err := parseEnrichFlag(c)
 	if err != nil {
-		log.Err(err).Msg("Failed to call usecase.UpdatePerson")
-		return c.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		return err
+	}
+
+	err = d.usecase.ReplacePerson(c.Request().Context(), id, version, req, enrich)
+	if err != nil {
+		log.Err(err).Msg("Failed to call usecase.ReplacePerson")
+		return err
+	}
+
+	return c.JSON(http.StatusOK, map[string]string{"message": "Person updated"})
+}
+
+func (d *Delivery) PatchPerson(c echo.Context) error {
+	log.Debug().Msg("Calling PatchPerson handler")
+	id, err := parseID(c)
+	if err != nil {
+		return err
+	}
+
+	version, matched, err := d.ifMatchVersion(c, id)
+	if err != nil {
+		return err
+	}
+	if !matched {
+		version = anyVersion
+	}
+
+	format, err := patchFormat(c)
+	if err != nil {
+		return err
+	}
+
+	patch, err := io.ReadAll(c.Request().Body)
+	if err != nil {
+		log.Err(err).Msg("Failed to read patch")
+		return err
+	}
+
+	enrich, err := parseEnrichFlag(c)
+	if err != nil {
+		return err
+	}
+
+	err = d.usecase.PatchPerson(c.Request().Context(), id, version, format, patch, enrich)
+	if err != nil {
+		log.Err(err).Msg("Failed to call usecase.PatchPerson")
+		return err
 	}
 
 	return c.JSON(http.StatusOK, map[string]string{"message": "Person updated"})
@@ -118,17 +308,176 @@ func (d *Delivery) UpdatePerson(c echo.Context) error {
 func (d *Delivery) DeletePerson(c echo.Context) error {
 	log.Debug().Msg("Calling DeletePerson handler")
 
-	id, err := strconv.Atoi(c.Param("id"))
+	id, err := parseID(c)
 	if err != nil {
-		log.Err(err).Msg("Failed to convert id to int")
-		return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+		return err
+	}
+
+	idempotent := false
+	if value := c.QueryParam("idempotent"); value != "" {
+		idempotent, err = strconv.ParseBool(value)
+		if err != nil {
+			log.Err(err).Msg("Failed to parse idempotent flag")
+			return queryError("idempotent", "must be a boolean")
+		}
+	}
+
+	version, matched, err := d.ifMatchVersion(c, id)
+	if err != nil {
+		return err
+	}
+	if !matched {
+		version = anyVersion
 	}
 
-	err = d.usecase.DeletePerson(context.Background(), id)
+	err = d.usecase.DeletePerson(c.Request().Context(), id, version, idempotent)
 	if err != nil {
 		log.Err(err).Msg("Failed to call usecase.DeletePerson")
-		return c.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		return err
 	}
 
 	return c.JSON(http.StatusOK, map[string]string{"message": "Person deleted"})
 }
+
+func (d *Delivery) RestorePerson(c echo.Context) error {
+	log.Debug().Msg("Calling RestorePerson handler")
+
+	id, err := parseID(c)
+	if err != nil {
+		return err
+	}
+
+	if err := d.usecase.RestorePerson(c.Request().Context(), id); err != nil {
+		log.Err(err).Msg("Failed to call usecase.RestorePerson")
+		return err
+	}
+
+	return c.JSON(http.StatusOK, map[string]string{"message": "Person restored"})
+}
+
+func (d *Delivery) PurgePeople(c echo.Context) error {
+	log.Debug().Msg("Calling PurgePeople handler")
+
+	purged, err := d.usecase.PurgePeople(c.Request().Context())
+	if err != nil {
+		log.Err(err).Msg("Failed to call usecase.PurgePeople")
+		return err
+	}
+
+	return c.JSON(http.StatusOK, map[string]int{"purged": purged})
+}
+
+func (d *Delivery) EnrichPerson(c echo.Context) error {
+	log.Debug().Msg("Calling EnrichPerson handler")
+
+	id, err := parseID(c)
+	if err != nil {
+		return err
+	}
+
+	overwrite, err := parseEnrichMode(c.QueryParam("mode"))
+	if err != nil {
+		log.Err(err).Msg("Failed to parse enrichment mode")
+		return err
+	}
+
+	err = d.usecase.EnrichPerson(c.Request().Context(), id, overwrite)
+	if err != nil {
+		log.Err(err).Msg("Failed to call usecase.EnrichPerson")
+		return err
+	}
+
+	return c.JSON(http.StatusAccepted, map[string]string{"message": "Enrichment requested"})
+}
+
+func (d *Delivery) EnrichPeople(c echo.Context) error {
+	log.Debug().Msg("Calling EnrichPeople handler")
+
+	params := make(map[string]interface{})
+	for key, value := range c.QueryParams() {
+		params[key] = value[0]
+	}
+	delete(params, "mode")
+
+	overwrite, err := parseEnrichMode(c.QueryParam("mode"))
+	if err != nil {
+		log.Err(err).Msg("Failed to parse enrichment mode")
+		return err
+	}
+
+	count, err := d.usecase.EnrichPeople(c.Request().Context(), params, overwrite)
+	if err != nil {
+		log.Err(err).Msg("Failed to call usecase.EnrichPeople")
+		return err
+	}
+
+	return c.JSON(http.StatusAccepted, map[string]int{"queued": count})
+}
+
+func parseEnrichMode(mode string) (bool, error) {
+	switch mode {
+	case "", "fill":
+		return false, nil
+	case "overwrite":
+		return true, nil
+	default:
+		return false, queryError("mode", "must be fill or overwrite")
+	}
+}
+
+const maxActorLength = 255
+
+const (
+	mergePatchContentType = "application/merge-patch+json"
+	jsonPatchContentType  = "application/json-patch+json"
+)
+
+// patchFormat picks the patch format from the Content-Type of the request.
+func patchFormat(c echo.Context) (string, error) {
+	mediaType, _, _ := mime.ParseMediaType(c.Request().Header.Get(echo.HeaderContentType))
+	switch mediaType {
+	case mergePatchContentType:
+		return entity.MergePatch, nil
+	case jsonPatchContentType:
+		return entity.JSONPatch, nil
+	default:
+		c.Response().Header().Set("Accept-Patch", mergePatchContentType+", "+jsonPatchContentType)
+		return "", echo.NewHTTPError(http.StatusUnsupportedMediaType,
+			fmt.Sprintf("Content-Type must be %s or %s", mergePatchContentType, jsonPatchContentType))
+	}
+}
+
+func parseEnrichFlag(c echo.Context) (bool, error) {
+	value := c.QueryParam("enrich")
+	if value == "" {
+		return true, nil
+	}
+	enrich, err := strconv.ParseBool(value)
+	if err != nil {
+		log.Err(err).Msg("Failed to parse enrich flag")
+		return false, queryError("enrich", "must be a boolean")
+	}
+	return enrich, nil
+}
+
+func parseID(c echo.Context) (int, error) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		log.Err(err).Msg("Failed to convert id to int")
+		var verr entity.ValidationError
+		verr.Add("id", entity.ViolationType, "must be an integer")
+		return 0, &verr
+	}
+	return id, nil
+}
+
+func queryError(param, message string) error {
+	var verr entity.ValidationError
+	verr.Add(param, entity.ViolationEnum, message)
+	return &verr
+}
+
+func (d *Delivery) GetEnrichmentStatus(c echo.Context) error {
+	log.Debug().Msg("Calling GetEnrichmentStatus handler")
+	return c.JSON(http.StatusOK, d.usecase.GetEnrichmentStatus(c.Request().Context()))
+}
@@ -0,0 +1,150 @@
+package enricher
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// maxBatchSize is the number of name[] parameters the providers accept.
+const maxBatchSize = 10
+
+type batchResult struct {
+	raw json.RawMessage
+	err error
+}
+
+// requestError is the failure of a single upstream request shared by every
+// lookup that took part in it.
+type requestError struct {
+	err     error
+	counted atomic.Bool
+}
+
+func (e *requestError) Error() string {
+	return e.err.Error()
+}
+
+func (e *requestError) Unwrap() error {
+	return e.err
+}
+
+// count reports whether the failure is seen for the first time.
+func (e *requestError) count() bool {
+	return e.counted.CompareAndSwap(false, true)
+}
+
+type batch struct {
+	countryID string
+	names     []string
+	waiters   map[string][]chan batchResult
+}
+
+// batcher coalesces concurrent lookups into multi-name requests. A batch is
+// sent when it is full or when window has passed since its first lookup, and
+// is given up after deadline.
+type batcher struct {
+	remote   remote
+	size     int
+	window   time.Duration
+	deadline time.Duration
+
+	mu      sync.Mutex
+	batches map[string]*batch
+}
+
+func newBatcher(r remote, size int, window, deadline time.Duration) *batcher {
+	if size > maxBatchSize {
+		size = maxBatchSize
+	}
+	return &batcher{
+		remote:   r,
+		size:     size,
+		window:   window,
+		deadline: deadline,
+		batches:  make(map[string]*batch),
+	}
+}
+
+func (b *batcher) lookup(ctx context.Context, name, countryID string) (json.RawMessage, error) {
+	done := make(chan batchResult, 1)
+
+	b.mu.Lock()
+	current, ok := b.batches[countryID]
+	if !ok {
+		current = &batch{countryID: countryID, waiters: make(map[string][]chan batchResult)}
+		b.batches[countryID] = current
+		time.AfterFunc(b.window, func() { b.flush(current) })
+	}
+	if _, ok := current.waiters[name]; !ok {
+		current.names = append(current.names, name)
+	}
+	current.waiters[name] = append(current.waiters[name], done)
+	full := len(current.names) >= b.size
+	if full {
+		delete(b.batches, countryID)
+	}
+	b.mu.Unlock()
+
+	if full {
+		go b.send(current)
+	}
+
+	select {
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	case result := <-done:
+		return result.raw, result.err
+	}
+}
+
+func (b *batcher) flush(current *batch) {
+	b.mu.Lock()
+	if b.batches[current.countryID] != current {
+		b.mu.Unlock()
+		return
+	}
+	delete(b.batches, current.countryID)
+	b.mu.Unlock()
+
+	b.send(current)
+}
+
+// send is detached from the callers' contexts since the request is shared.
+// deadline bounds it instead, including retries and Retry-After pauses.
+func (b *batcher) send(current *batch) {
+	ctx := context.Background()
+	if b.deadline > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, b.deadline)
+		defer cancel()
+	}
+
+	query := url.Values{"name[]": current.names}
+	if current.countryID != "" {
+		query.Set("country_id", current.countryID)
+	}
+
+	var responses []json.RawMessage
+	err := b.remote.getJSON(ctx, query, &responses)
+	if err == nil && len(responses) != len(current.names) {
+		err = fmt.Errorf("expected %d results in batch, got %d", len(current.names), len(responses))
+	}
+	if err != nil {
+		err = &requestError{err: err}
+	}
+
+	for i, name := range current.names {
+		result := batchResult{err: err}
+		if err == nil {
+			result.raw = responses[i]
+		}
+		for _, done := range current.waiters[name] {
+			done <- result
+		}
+	}
+}
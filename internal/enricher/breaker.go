@@ -0,0 +1,136 @@
+package enricher
+
+import (
+	"context"
+	"errors"
+	"github.com/OksidGen/enrich_server/internal/entity"
+	"github.com/rs/zerolog/log"
+	"sync"
+	"time"
+)
+
+const (
+	BreakerClosed   = "closed"
+	BreakerOpen     = "open"
+	BreakerHalfOpen = "half-open"
+)
+
+var ErrCircuitOpen = errors.New("circuit breaker is open")
+
+type BreakerStatus struct {
+	State     string     `json:"state"`
+	Failures  int        `json:"failures"`
+	OpenUntil *time.Time `json:"open_until,omitempty"`
+}
+
+// breaker stops calling a provider after threshold consecutive failures and
+// lets a single trial request through once cooldown has passed.
+type breaker struct {
+	Provider
+	threshold int
+	cooldown  time.Duration
+
+	mu        sync.Mutex
+	state     string
+	failures  int
+	openUntil time.Time
+}
+
+func WithBreaker(provider Provider, threshold int, cooldown time.Duration) Provider {
+	return &breaker{
+		Provider:  provider,
+		threshold: threshold,
+		cooldown:  cooldown,
+		state:     BreakerClosed,
+	}
+}
+
+func (b *breaker) Lookup(ctx context.Context, person entity.Person) (Result, error) {
+	if !b.allow() {
+		return Result{}, ErrCircuitOpen
+	}
+
+	result, err := b.Provider.Lookup(ctx, person)
+	switch {
+	case err == nil || errors.Is(err, ErrNoData):
+		b.succeed()
+	case ctx.Err() != nil:
+		b.release()
+	default:
+		b.fail(err)
+	}
+	return result, err
+}
+
+func (b *breaker) allow() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	switch b.state {
+	case BreakerOpen:
+		if time.Now().Before(b.openUntil) {
+			return false
+		}
+		b.state = BreakerHalfOpen
+		return true
+	case BreakerHalfOpen:
+		return false
+	default:
+		return true
+	}
+}
+
+func (b *breaker) succeed() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.state != BreakerClosed {
+		log.Info().Str("provider", b.Name()).Msg("Circuit breaker closed")
+	}
+	b.state = BreakerClosed
+	b.failures = 0
+}
+
+// release returns a half-open breaker to open without counting the
+// interrupted trial against the provider.
+func (b *breaker) release() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.state == BreakerHalfOpen {
+		b.state = BreakerOpen
+	}
+}
+
+// fail counts a failed upstream request. Lookups that shared a batched
+// request report its failure once each, but it is counted only once.
+func (b *breaker) fail(err error) {
+	var reqErr *requestError
+	if errors.As(err, &reqErr) && !reqErr.count() {
+		return
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.failures++
+	if b.state == BreakerHalfOpen || b.failures >= b.threshold {
+		b.state = BreakerOpen
+		b.openUntil = time.Now().Add(b.cooldown)
+		log.Warn().Str("provider", b.Name()).Int("failures", b.failures).Time("open_until", b.openUntil).Msg("Circuit breaker opened")
+	}
+}
+
+func (b *breaker) Status() ProviderStatus {
+	status := statusOf(b.Provider)
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	status.Breaker = &BreakerStatus{State: b.state, Failures: b.failures}
+	if b.state != BreakerClosed {
+		openUntil := b.openUntil
+		status.Breaker.OpenUntil = &openUntil
+	}
+	return status
+}
@@ -0,0 +1,170 @@
+package enricher
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/OksidGen/enrich_server/internal/entity"
+)
+
+var errUpstream = errors.New("upstream failed")
+
+// stubProvider answers every lookup with err.
+type stubProvider struct {
+	mu    sync.Mutex
+	err   error
+	calls int
+}
+
+func (p *stubProvider) Name() string      { return "stub" }
+func (p *stubProvider) Attribute() string { return AttributeGender }
+
+func (p *stubProvider) Lookup(ctx context.Context, person entity.Person) (Result, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.calls++
+	return Result{Attribute: AttributeGender, Value: "male"}, p.err
+}
+
+func (p *stubProvider) fail(err error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.err = err
+}
+
+func breakerState(t *testing.T, provider Provider) BreakerStatus {
+	t.Helper()
+	return *provider.(*breaker).Status().Breaker
+}
+
+func TestBreakerOpensAfterThreshold(t *testing.T) {
+	stub := &stubProvider{err: errUpstream}
+	provider := WithBreaker(stub, 3, time.Hour)
+
+	for i := 0; i < 2; i++ {
+		if _, err := provider.Lookup(context.Background(), entity.Person{}); !errors.Is(err, errUpstream) {
+			t.Fatalf("lookup %d: error = %v, want upstream error", i, err)
+		}
+		if state := breakerState(t, provider); state.State != BreakerClosed || state.Failures != i+1 {
+			t.Fatalf("after %d failures: %+v, want closed", i+1, state)
+		}
+	}
+
+	provider.Lookup(context.Background(), entity.Person{})
+	if state := breakerState(t, provider); state.State != BreakerOpen || state.OpenUntil == nil {
+		t.Fatalf("after threshold: %+v, want open", state)
+	}
+
+	if _, err := provider.Lookup(context.Background(), entity.Person{}); !errors.Is(err, ErrCircuitOpen) {
+		t.Fatalf("open breaker: error = %v, want ErrCircuitOpen", err)
+	}
+	if stub.calls != 3 {
+		t.Errorf("provider called %d times, want 3", stub.calls)
+	}
+}
+
+func TestBreakerSuccessResetsFailures(t *testing.T) {
+	stub := &stubProvider{err: errUpstream}
+	provider := WithBreaker(stub, 2, time.Hour)
+
+	provider.Lookup(context.Background(), entity.Person{})
+	stub.fail(ErrNoData)
+	provider.Lookup(context.Background(), entity.Person{})
+	stub.fail(errUpstream)
+	provider.Lookup(context.Background(), entity.Person{})
+
+	if state := breakerState(t, provider); state.State != BreakerClosed || state.Failures != 1 {
+		t.Errorf("state = %+v, want closed with 1 failure", state)
+	}
+}
+
+func TestBreakerHalfOpen(t *testing.T) {
+	tests := []struct {
+		name  string
+		trial error
+		want  string
+	}{
+		{"successful trial closes", nil, BreakerClosed},
+		{"failed trial reopens", errUpstream, BreakerOpen},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			stub := &stubProvider{err: errUpstream}
+			provider := WithBreaker(stub, 1, time.Millisecond)
+			provider.Lookup(context.Background(), entity.Person{})
+			time.Sleep(5 * time.Millisecond)
+
+			b := provider.(*breaker)
+			if !b.allow() {
+				t.Fatal("trial after cooldown was not allowed")
+			}
+			if state := breakerState(t, provider); state.State != BreakerHalfOpen {
+				t.Fatalf("state = %s, want half-open", state.State)
+			}
+			if _, err := provider.Lookup(context.Background(), entity.Person{}); !errors.Is(err, ErrCircuitOpen) {
+				t.Fatalf("second request while half-open: error = %v, want ErrCircuitOpen", err)
+			}
+
+			if tt.trial == nil {
+				b.succeed()
+			} else {
+				b.fail(tt.trial)
+			}
+			if state := breakerState(t, provider); state.State != tt.want {
+				t.Errorf("state = %s, want %s", state.State, tt.want)
+			}
+		})
+	}
+}
+
+func TestBreakerInterruptedTrialReopens(t *testing.T) {
+	stub := &stubProvider{err: errUpstream}
+	provider := WithBreaker(stub, 1, time.Millisecond)
+	provider.Lookup(context.Background(), entity.Person{})
+	time.Sleep(5 * time.Millisecond)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	provider.Lookup(ctx, entity.Person{})
+
+	if state := breakerState(t, provider); state.State != BreakerOpen || state.Failures != 1 {
+		t.Errorf("state = %+v, want open without another failure", state)
+	}
+}
+
+func TestBreakerCountsBatchOnce(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadGateway)
+	}))
+	defer server.Close()
+
+	const names = 5
+	provider := WithBreaker(NewGenderize(Options{
+		URL:         server.URL,
+		Timeout:     time.Second,
+		BatchSize:   names,
+		BatchWindow: time.Second,
+	}), 2, time.Hour)
+
+	var wg sync.WaitGroup
+	for i := 0; i < names; i++ {
+		wg.Add(1)
+		go func(name string) {
+			defer wg.Done()
+			if _, err := provider.Lookup(context.Background(), entity.Person{Name: name}); err == nil {
+				t.Errorf("lookup of %s succeeded", name)
+			}
+		}(string(rune('a' + i)))
+	}
+	wg.Wait()
+
+	if state := breakerState(t, provider); state.State != BreakerClosed || state.Failures != 1 {
+		t.Errorf("state = %+v, want closed with 1 failure", state)
+	}
+}
@@ -0,0 +1,224 @@
+package enricher
+
+import (
+	"container/list"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/OksidGen/enrich_server/internal/entity"
+	"github.com/rs/zerolog/log"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// CacheStore persists cached results between restarts.
+type CacheStore interface {
+	GetCachedResult(ctx context.Context, provider, name string) ([]byte, bool, error)
+	SetCachedResult(ctx context.Context, provider, name string, payload []byte, expiresAt time.Time) error
+}
+
+// Cache is an LRU of provider results keyed by normalized first name, with an
+// optional CacheStore behind it.
+type Cache struct {
+	mu      sync.Mutex
+	size    int
+	ttl     time.Duration
+	entries map[string]*list.Element
+	order   *list.List
+	store   CacheStore
+}
+
+type cacheEntry struct {
+	key       string
+	result    Result
+	expiresAt time.Time
+}
+
+func NewCache(size int, ttl time.Duration, store CacheStore) *Cache {
+	return &Cache{
+		size:    size,
+		ttl:     ttl,
+		entries: make(map[string]*list.Element),
+		order:   list.New(),
+		store:   store,
+	}
+}
+
+func cacheKey(person entity.Person) string {
+	name := strings.ToLower(strings.TrimSpace(person.Name))
+	if person.CountryID != "" {
+		name += "@" + person.CountryID
+	}
+	return name
+}
+
+func (c *Cache) Get(ctx context.Context, provider, name string) (Result, bool) {
+	key := provider + ":" + name
+
+	c.mu.Lock()
+	if element, ok := c.entries[key]; ok {
+		entry := element.Value.(*cacheEntry)
+		if time.Now().Before(entry.expiresAt) {
+			c.order.MoveToFront(element)
+			c.mu.Unlock()
+			return entry.result, true
+		}
+		c.order.Remove(element)
+		delete(c.entries, key)
+	}
+	c.mu.Unlock()
+
+	if c.store == nil {
+		return Result{}, false
+	}
+
+	payload, found, err := c.store.GetCachedResult(ctx, provider, name)
+	if err != nil {
+		log.Err(err).Str("provider", provider).Str("name", name).Msg("Failed to read cached result")
+		return Result{}, false
+	}
+	if !found {
+		return Result{}, false
+	}
+
+	var result Result
+	if err := json.Unmarshal(payload, &result); err != nil {
+		log.Err(err).Str("provider", provider).Str("name", name).Msg("Failed to decode cached result")
+		return Result{}, false
+	}
+	c.remember(key, result, time.Now().Add(c.ttl))
+	return result, true
+}
+
+func (c *Cache) Set(ctx context.Context, provider, name string, result Result) {
+	expiresAt := time.Now().Add(c.ttl)
+	c.remember(provider+":"+name, result, expiresAt)
+
+	if c.store == nil {
+		return
+	}
+
+	payload, err := json.Marshal(result)
+	if err != nil {
+		log.Err(err).Str("provider", provider).Msg("Failed to encode cached result")
+		return
+	}
+	if err := c.store.SetCachedResult(ctx, provider, name, payload, expiresAt); err != nil {
+		log.Err(err).Str("provider", provider).Str("name", name).Msg("Failed to store cached result")
+	}
+}
+
+func (c *Cache) remember(key string, result Result, expiresAt time.Time) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if element, ok := c.entries[key]; ok {
+		element.Value = &cacheEntry{key, result, expiresAt}
+		c.order.MoveToFront(element)
+		return
+	}
+
+	c.entries[key] = c.order.PushFront(&cacheEntry{key, result, expiresAt})
+	for c.order.Len() > c.size {
+		oldest := c.order.Back()
+		c.order.Remove(oldest)
+		delete(c.entries, oldest.Value.(*cacheEntry).key)
+	}
+}
+
+type cachedProvider struct {
+	Provider
+	cache  *Cache
+	hits   atomic.Uint64
+	misses atomic.Uint64
+}
+
+// Cached puts cache in front of provider. Names the provider knows nothing
+// about are cached as well.
+func Cached(provider Provider, cache *Cache) Provider {
+	return &cachedProvider{Provider: provider, cache: cache}
+}
+
+func (p *cachedProvider) Lookup(ctx context.Context, person entity.Person) (Result, error) {
+	name := cacheKey(person)
+
+	if result, ok := p.cache.Get(ctx, p.Name(), name); ok {
+		p.hits.Add(1)
+		if result.Value == nil {
+			return Result{}, ErrNoData
+		}
+		return result, nil
+	}
+	p.misses.Add(1)
+
+	result, err := p.Provider.Lookup(ctx, person)
+	if errors.Is(err, ErrNoData) {
+		p.cache.Set(ctx, p.Name(), name, Result{Attribute: p.Attribute()})
+		return Result{}, err
+	}
+	if err != nil {
+		return Result{}, err
+	}
+
+	p.cache.Set(ctx, p.Name(), name, result)
+	return result, nil
+}
+
+func (p *cachedProvider) Status() ProviderStatus {
+	status := statusOf(p.Provider)
+	status.Cache = &CacheStatus{
+		Hits:   p.hits.Load(),
+		Misses: p.misses.Load(),
+	}
+	return status
+}
+
+// UnmarshalJSON restores the concrete type of Value, which a plain decode
+// would turn into float64 for numeric attributes.
+func (r *Result) UnmarshalJSON(data []byte) error {
+	var raw struct {
+		Attribute    string
+		Source       string
+		Value        json.RawMessage
+		Probability  float64
+		Count        int
+		Candidates   []entity.NationalityCandidate
+		ResponseHash string
+	}
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return err
+	}
+
+	*r = Result{
+		Attribute:    raw.Attribute,
+		Source:       raw.Source,
+		Probability:  raw.Probability,
+		Count:        raw.Count,
+		Candidates:   raw.Candidates,
+		ResponseHash: raw.ResponseHash,
+	}
+	if len(raw.Value) == 0 || string(raw.Value) == "null" {
+		return nil
+	}
+
+	switch raw.Attribute {
+	case AttributeAge:
+		var age int
+		if err := json.Unmarshal(raw.Value, &age); err != nil {
+			return err
+		}
+		r.Value = age
+	case AttributeGender, AttributeNationality:
+		var value string
+		if err := json.Unmarshal(raw.Value, &value); err != nil {
+			return err
+		}
+		r.Value = value
+	default:
+		return fmt.Errorf("unknown attribute: %s", raw.Attribute)
+	}
+	return nil
+}
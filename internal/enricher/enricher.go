@@ -0,0 +1,275 @@
+package enricher
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/OksidGen/enrich_server/internal/entity"
+	"github.com/rs/zerolog/log"
+	"time"
+)
+
+const (
+	AttributeAge         = "age"
+	AttributeGender      = "gender"
+	AttributeNationality = "nationality"
+)
+
+// ErrNoData is returned by a Provider that has nothing to say about a name.
+// Unlike other errors it does not leave the attribute pending.
+var ErrNoData = errors.New("no data for name")
+
+// Enricher fills derived attributes of a person. When PendingAttributes is
+// not empty only the listed attributes are resolved. LockedAttributes are
+// never touched.
+type Enricher interface {
+	Attributes() []string
+	Enrich(ctx context.Context, person *entity.Person) error
+}
+
+// Provider resolves a single attribute of a person.
+type Provider interface {
+	Name() string
+	Attribute() string
+	Lookup(ctx context.Context, person entity.Person) (Result, error)
+}
+
+type Result struct {
+	Attribute    string
+	Source       string
+	Value        interface{}
+	Probability  float64
+	Count        int
+	Candidates   []entity.NationalityCandidate
+	ResponseHash string
+}
+
+// Chain is an Enricher built from registered providers. Attributes are
+// resolved concurrently; providers of the same attribute are tried in
+// registration order until one of them succeeds.
+type Chain struct {
+	deadline  time.Duration
+	providers []Provider
+}
+
+func NewChain(deadline time.Duration, providers ...Provider) *Chain {
+	return &Chain{deadline, providers}
+}
+
+func (c *Chain) Register(provider Provider) {
+	c.providers = append(c.providers, provider)
+}
+
+func (c *Chain) Attributes() []string {
+	attributes, _ := c.byAttribute()
+	return attributes
+}
+
+type outcome struct {
+	attribute string
+	result    Result
+	err       error
+}
+
+// Enrich applies every attribute resolved before the deadline. Attributes
+// that failed or did not make it in time are listed in PendingAttributes.
+func (c *Chain) Enrich(ctx context.Context, person *entity.Person) error {
+	log.Debug().Str("name", person.Name).Msg("Enriching person data")
+
+	if person.Name == "" {
+		return nil
+	}
+
+	if c.deadline > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, c.deadline)
+		defer cancel()
+	}
+
+	attributes, providers := c.byAttribute()
+	var requested []string
+	for _, attribute := range attributes {
+		if person.LockedAttributes.Contains(attribute) {
+			continue
+		}
+		if len(person.PendingAttributes) != 0 && !person.PendingAttributes.Contains(attribute) {
+			continue
+		}
+		requested = append(requested, attribute)
+	}
+	attributes = requested
+
+	outcomes := make(chan outcome, len(attributes))
+	snapshot := *person
+	for _, attribute := range attributes {
+		go func(attribute string, providers []Provider) {
+			result, err := lookup(ctx, snapshot, providers)
+			outcomes <- outcome{attribute, result, err}
+		}(attribute, providers[attribute])
+	}
+
+	pending := make(map[string]bool, len(attributes))
+	for _, attribute := range attributes {
+		pending[attribute] = true
+	}
+
+	var errs []error
+collect:
+	for received := 0; received < len(attributes); received++ {
+		select {
+		case o := <-outcomes:
+			if errors.Is(o.err, ErrNoData) {
+				delete(pending, o.attribute)
+				continue
+			}
+			if o.err != nil {
+				errs = append(errs, o.err)
+				continue
+			}
+			if err := apply(person, o.result); err != nil {
+				errs = append(errs, fmt.Errorf("%s: %w", o.attribute, err))
+				continue
+			}
+			delete(pending, o.attribute)
+		case <-ctx.Done():
+			errs = append(errs, fmt.Errorf("enrichment interrupted: %w", ctx.Err()))
+			break collect
+		}
+	}
+
+	person.PendingAttributes = nil
+	for _, attribute := range attributes {
+		if pending[attribute] {
+			person.PendingAttributes = append(person.PendingAttributes, attribute)
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+func apply(person *entity.Person, result Result) error {
+	switch result.Attribute {
+	case AttributeAge:
+		age, ok := result.Value.(int)
+		if !ok {
+			return fmt.Errorf("unexpected age %v of type %T", result.Value, result.Value)
+		}
+		person.Age = &age
+		person.AgeCount = result.Count
+	case AttributeGender:
+		gender, ok := result.Value.(string)
+		if !ok {
+			return fmt.Errorf("unexpected gender %v of type %T", result.Value, result.Value)
+		}
+		person.Gender = &gender
+		person.GenderProbability = result.Probability
+		person.GenderSource = result.Source
+	case AttributeNationality:
+		nationality, ok := result.Value.(string)
+		if !ok {
+			return fmt.Errorf("unexpected nationality %v of type %T", result.Value, result.Value)
+		}
+		person.Nationality = &nationality
+		person.NationalityProbability = result.Probability
+		person.Nationalities = uniqueCandidates(result.Candidates)
+	default:
+		return fmt.Errorf("unknown attribute %q", result.Attribute)
+	}
+
+	person.Provenance = append(person.Provenance, entity.Provenance{
+		Field:        result.Attribute,
+		Value:        fmt.Sprint(result.Value),
+		Source:       result.Source,
+		ResponseHash: result.ResponseHash,
+	})
+	return nil
+}
+
+// uniqueCandidates keeps the most probable entry of every country, in the
+// order the countries first appear. Providers may list a country twice.
+func uniqueCandidates(candidates []entity.NationalityCandidate) []entity.NationalityCandidate {
+	var unique []entity.NationalityCandidate
+	seen := make(map[string]int, len(candidates))
+	for _, candidate := range candidates {
+		if i, ok := seen[candidate.CountryID]; ok {
+			if candidate.Probability > unique[i].Probability {
+				unique[i].Probability = candidate.Probability
+			}
+			continue
+		}
+		seen[candidate.CountryID] = len(unique)
+		unique = append(unique, candidate)
+	}
+	return unique
+}
+
+// Empty reports whether attribute has not been filled for person yet.
+func Empty(person entity.Person, attribute string) bool {
+	switch attribute {
+	case AttributeAge:
+		return person.Age == nil
+	case AttributeGender:
+		return person.Gender == nil
+	case AttributeNationality:
+		return person.Nationality == nil
+	}
+	return true
+}
+
+// Clear forgets attribute of person together with everything resolved with it.
+func Clear(person *entity.Person, attribute string) {
+	switch attribute {
+	case AttributeAge:
+		person.Age = nil
+		person.AgeCount = 0
+	case AttributeGender:
+		person.Gender = nil
+		person.GenderProbability = 0
+		person.GenderSource = ""
+	case AttributeNationality:
+		person.Nationality = nil
+		person.NationalityProbability = 0
+		person.Nationalities = nil
+	}
+}
+
+func (c *Chain) byAttribute() ([]string, map[string][]Provider) {
+	var attributes []string
+	providers := make(map[string][]Provider)
+	for _, provider := range c.providers {
+		attribute := provider.Attribute()
+		if _, ok := providers[attribute]; !ok {
+			attributes = append(attributes, attribute)
+		}
+		providers[attribute] = append(providers[attribute], provider)
+	}
+	return attributes, providers
+}
+
+func lookup(ctx context.Context, person entity.Person, providers []Provider) (Result, error) {
+	var errs []error
+	noData := true
+	for _, provider := range providers {
+		result, err := provider.Lookup(ctx, person)
+		if err == nil {
+			if result.Source == "" {
+				result.Source = provider.Name()
+			}
+			return result, nil
+		}
+
+		log.Err(err).Str("provider", provider.Name()).Str("name", person.Name).Msg("Failed to lookup attribute")
+		errs = append(errs, fmt.Errorf("%s: %w", provider.Name(), err))
+		if !errors.Is(err, ErrNoData) {
+			noData = false
+		}
+		if ctx.Err() != nil {
+			break
+		}
+	}
+
+	if noData {
+		return Result{}, ErrNoData
+	}
+	return Result{}, errors.Join(errs...)
+}
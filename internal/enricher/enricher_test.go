@@ -0,0 +1,34 @@
+package enricher
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/OksidGen/enrich_server/internal/entity"
+)
+
+func TestApplyDeduplicatesNationalities(t *testing.T) {
+	var person entity.Person
+	err := apply(&person, Result{
+		Attribute:   AttributeNationality,
+		Value:       "RU",
+		Probability: 0.4,
+		Candidates: []entity.NationalityCandidate{
+			{CountryID: "RU", Probability: 0.4},
+			{CountryID: "UA", Probability: 0.2},
+			{CountryID: "RU", Probability: 0.5},
+			{CountryID: "UA", Probability: 0.1},
+		},
+	})
+	if err != nil {
+		t.Fatalf("apply: %v", err)
+	}
+
+	want := []entity.NationalityCandidate{
+		{CountryID: "RU", Probability: 0.5},
+		{CountryID: "UA", Probability: 0.2},
+	}
+	if !reflect.DeepEqual(person.Nationalities, want) {
+		t.Errorf("nationalities = %v, want %v", person.Nationalities, want)
+	}
+}
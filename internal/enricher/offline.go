@@ -0,0 +1,151 @@
+package enricher
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"github.com/OksidGen/enrich_server/internal/entity"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+type datasetRecord struct {
+	Name        string `json:"name"`
+	Age         int    `json:"age"`
+	Gender      string `json:"gender"`
+	Nationality string `json:"nationality"`
+}
+
+// Dataset is a name -> attributes table loaded from disk for offline
+// enrichment.
+type Dataset struct {
+	records map[string]datasetRecord
+}
+
+// LoadDataset reads a JSON array of records or a CSV file with a
+// name,age,gender,nationality header, depending on the file extension.
+func LoadDataset(path string) (*Dataset, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	var records []datasetRecord
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".json":
+		err = json.NewDecoder(file).Decode(&records)
+	case ".csv":
+		records, err = readCSVDataset(file)
+	default:
+		err = fmt.Errorf("unsupported dataset format: %s", path)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to load dataset %s: %w", path, err)
+	}
+
+	dataset := &Dataset{records: make(map[string]datasetRecord, len(records))}
+	for _, record := range records {
+		dataset.records[strings.ToLower(strings.TrimSpace(record.Name))] = record
+	}
+	return dataset, nil
+}
+
+func readCSVDataset(r io.Reader) ([]datasetRecord, error) {
+	reader := csv.NewReader(r)
+	header, err := reader.Read()
+	if err != nil {
+		return nil, err
+	}
+
+	columns := make(map[string]int, len(header))
+	for i, column := range header {
+		columns[strings.ToLower(strings.TrimSpace(column))] = i
+	}
+	if _, ok := columns["name"]; !ok {
+		return nil, fmt.Errorf("missing name column")
+	}
+	field := func(row []string, column string) string {
+		if i, ok := columns[column]; ok && i < len(row) {
+			return strings.TrimSpace(row[i])
+		}
+		return ""
+	}
+
+	var records []datasetRecord
+	for {
+		row, err := reader.Read()
+		if err == io.EOF {
+			return records, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		record := datasetRecord{
+			Name:        field(row, "name"),
+			Gender:      field(row, "gender"),
+			Nationality: field(row, "nationality"),
+		}
+		if age := field(row, "age"); age != "" {
+			if record.Age, err = strconv.Atoi(age); err != nil {
+				return nil, fmt.Errorf("invalid age %q for %s", age, record.Name)
+			}
+		}
+		records = append(records, record)
+	}
+}
+
+// Providers returns an offline Provider for every attribute of the dataset.
+func (d *Dataset) Providers() []Provider {
+	return []Provider{
+		&offline{d, AttributeAge},
+		&offline{d, AttributeGender},
+		&offline{d, AttributeNationality},
+	}
+}
+
+type offline struct {
+	dataset   *Dataset
+	attribute string
+}
+
+func (p *offline) Name() string {
+	return "offline"
+}
+
+func (p *offline) Attribute() string {
+	return p.attribute
+}
+
+func (p *offline) Lookup(ctx context.Context, person entity.Person) (Result, error) {
+	record, ok := p.dataset.records[strings.ToLower(strings.TrimSpace(person.Name))]
+	if !ok {
+		return Result{}, ErrNoData
+	}
+
+	var value interface{}
+	switch p.attribute {
+	case AttributeAge:
+		if record.Age != 0 {
+			value = record.Age
+		}
+	case AttributeGender:
+		if record.Gender != "" {
+			value = record.Gender
+		}
+	case AttributeNationality:
+		if record.Nationality != "" {
+			value = record.Nationality
+		}
+	}
+	if value == nil {
+		return Result{}, ErrNoData
+	}
+
+	return Result{Attribute: p.attribute, Value: value}, nil
+}
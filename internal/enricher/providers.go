@@ -0,0 +1,135 @@
+package enricher
+
+import (
+	"context"
+	"github.com/OksidGen/enrich_server/internal/entity"
+	"github.com/rs/zerolog/log"
+)
+
+const (
+	agifyAPI       = "https://api.agify.io/"
+	genderizeAPI   = "https://api.genderize.io/"
+	nationalizeAPI = "https://api.nationalize.io/"
+)
+
+type agify struct {
+	remote
+}
+
+func NewAgify(opts Options) Provider {
+	return &agify{newRemote(opts, agifyAPI)}
+}
+
+func (p *agify) Name() string {
+	return "agify"
+}
+
+func (p *agify) Attribute() string {
+	return AttributeAge
+}
+
+func (p *agify) Lookup(ctx context.Context, person entity.Person) (Result, error) {
+	log.Debug().Str("name", person.Name).Msg("Getting age")
+
+	var ageResponse struct {
+		Age   *int `json:"age"`
+		Count int  `json:"count"`
+	}
+	hash, err := p.lookup(ctx, person, true, &ageResponse)
+	if err != nil {
+		return Result{}, err
+	}
+	if ageResponse.Age == nil {
+		return Result{}, ErrNoData
+	}
+
+	return Result{
+		Attribute:    AttributeAge,
+		Value:        *ageResponse.Age,
+		Count:        ageResponse.Count,
+		ResponseHash: hash,
+	}, nil
+}
+
+type genderize struct {
+	remote
+}
+
+func NewGenderize(opts Options) Provider {
+	return &genderize{newRemote(opts, genderizeAPI)}
+}
+
+func (p *genderize) Name() string {
+	return "genderize"
+}
+
+func (p *genderize) Attribute() string {
+	return AttributeGender
+}
+
+func (p *genderize) Lookup(ctx context.Context, person entity.Person) (Result, error) {
+	log.Debug().Str("name", person.Name).Msg("Getting gender")
+
+	var genderResponse struct {
+		Gender      *string `json:"gender"`
+		Probability float64 `json:"probability"`
+		Count       int     `json:"count"`
+	}
+	hash, err := p.lookup(ctx, person, true, &genderResponse)
+	if err != nil {
+		return Result{}, err
+	}
+	if genderResponse.Gender == nil {
+		return Result{}, ErrNoData
+	}
+
+	return Result{
+		Attribute:    AttributeGender,
+		Value:        *genderResponse.Gender,
+		Probability:  genderResponse.Probability,
+		Count:        genderResponse.Count,
+		ResponseHash: hash,
+	}, nil
+}
+
+type nationalize struct {
+	remote
+}
+
+func NewNationalize(opts Options) Provider {
+	return &nationalize{newRemote(opts, nationalizeAPI)}
+}
+
+func (p *nationalize) Name() string {
+	return "nationalize"
+}
+
+func (p *nationalize) Attribute() string {
+	return AttributeNationality
+}
+
+func (p *nationalize) Lookup(ctx context.Context, person entity.Person) (Result, error) {
+	log.Debug().Str("name", person.Name).Msg("Getting nationality")
+
+	var nationalityResponse struct {
+		Count   int                           `json:"count"`
+		Country []entity.NationalityCandidate `json:"country"`
+	}
+	hash, err := p.lookup(ctx, person, false, &nationalityResponse)
+	if err != nil {
+		return Result{}, err
+	}
+	if len(nationalityResponse.Country) == 0 {
+		return Result{}, ErrNoData
+	}
+
+	best := nationalityResponse.Country[0]
+	return Result{
+		Attribute:    AttributeNationality,
+		Value:        best.CountryID,
+		Probability:  best.Probability,
+		Count:        nationalityResponse.Count,
+		Candidates:   nationalityResponse.Country,
+		ResponseHash: hash,
+	}, nil
+}
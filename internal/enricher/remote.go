@@ -0,0 +1,201 @@
+package enricher
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"github.com/OksidGen/enrich_server/internal/entity"
+	"github.com/rs/zerolog/log"
+	"io"
+	"math/rand"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+)
+
+// Options configure a remote provider. BatchDeadline bounds a batched
+// request including its retries; without it the time the retries may take at
+// most is used.
+type Options struct {
+	URL           string
+	APIKey        string
+	Timeout       time.Duration
+	Retries       int
+	Backoff       time.Duration
+	MaxBackoff    time.Duration
+	BatchSize     int
+	BatchWindow   time.Duration
+	BatchDeadline time.Duration
+}
+
+type remote struct {
+	client     *http.Client
+	url        string
+	apiKey     string
+	retries    int
+	backoff    time.Duration
+	maxBackoff time.Duration
+	batcher    *batcher
+}
+
+func newRemote(opts Options, defaultURL string) remote {
+	if opts.URL == "" {
+		opts.URL = defaultURL
+	}
+	r := remote{
+		client:     &http.Client{Timeout: opts.Timeout},
+		url:        opts.URL,
+		apiKey:     opts.APIKey,
+		retries:    opts.Retries,
+		backoff:    opts.Backoff,
+		maxBackoff: opts.MaxBackoff,
+	}
+	if opts.BatchSize > 1 {
+		deadline := opts.BatchDeadline
+		if deadline <= 0 {
+			deadline = opts.Timeout*time.Duration(opts.Retries+1) + opts.MaxBackoff*time.Duration(opts.Retries)
+		}
+		r.batcher = newBatcher(r, opts.BatchSize, opts.BatchWindow, deadline)
+	}
+	return r
+}
+
+// lookup fetches the provider response for person into target, going through
+// the batcher when batching is enabled, and returns the SHA-256 of the raw
+// response. agify and genderize narrow their statistics down to the country
+// hint, nationalize has no use for it.
+func (r remote) lookup(ctx context.Context, person entity.Person, withCountry bool, target interface{}) (string, error) {
+	var countryID string
+	if withCountry {
+		countryID = person.CountryID
+	}
+
+	var raw json.RawMessage
+	var err error
+	if r.batcher != nil {
+		raw, err = r.batcher.lookup(ctx, person.Name, countryID)
+	} else {
+		query := url.Values{"name": {person.Name}}
+		if countryID != "" {
+			query.Set("country_id", countryID)
+		}
+		err = r.getJSON(ctx, query, &raw)
+	}
+	if err != nil {
+		return "", err
+	}
+
+	if err := json.Unmarshal(raw, target); err != nil {
+		return "", fmt.Errorf("failed to unmarshal response: %w", err)
+	}
+	hash := sha256.Sum256(raw)
+	return hex.EncodeToString(hash[:]), nil
+}
+
+type statusError struct {
+	code       int
+	body       string
+	retryAfter time.Duration
+}
+
+func (e *statusError) Error() string {
+	return fmt.Sprintf("unexpected status %d: %s", e.code, e.body)
+}
+
+func (e *statusError) retryable() bool {
+	return e.code == http.StatusTooManyRequests || e.code >= http.StatusInternalServerError
+}
+
+func (r remote) getJSON(ctx context.Context, query url.Values, target interface{}) error {
+	var err error
+	for attempt := 0; attempt <= r.retries; attempt++ {
+		if attempt > 0 {
+			delay := r.delay(attempt, err)
+			log.Debug().Err(err).Str("url", r.url).Int("attempt", attempt).Dur("delay", delay).Msg("Retrying request")
+
+			select {
+			case <-ctx.Done():
+				return err
+			case <-time.After(delay):
+			}
+		}
+
+		err = r.fetch(ctx, query, target)
+		if err == nil || ctx.Err() != nil {
+			return err
+		}
+		if statusErr, ok := err.(*statusError); ok && !statusErr.retryable() {
+			return err
+		}
+	}
+	return err
+}
+
+// delay returns a full-jitter exponential backoff, or Retry-After when the
+// provider asked for a longer pause.
+func (r remote) delay(attempt int, err error) time.Duration {
+	backoff := r.backoff << (attempt - 1)
+	if backoff <= 0 || (r.maxBackoff > 0 && backoff > r.maxBackoff) {
+		backoff = r.maxBackoff
+	}
+
+	var delay time.Duration
+	if backoff > 0 {
+		delay = time.Duration(rand.Int63n(int64(backoff)) + 1)
+	}
+
+	if statusErr, ok := err.(*statusError); ok && statusErr.retryAfter > delay {
+		delay = statusErr.retryAfter
+	}
+	return delay
+}
+
+func (r remote) fetch(ctx context.Context, query url.Values, target interface{}) error {
+	if r.apiKey != "" {
+		query.Set("apikey", r.apiKey)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, r.url+"?"+query.Encode(), nil)
+	if err != nil {
+		return err
+	}
+
+	resp, err := r.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer func(Body io.ReadCloser) {
+		err := Body.Close()
+		if err != nil {
+			log.Err(err).Msg("Failed to close response body")
+		}
+	}(resp.Body)
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return fmt.Errorf("failed to read response body: %w", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return &statusError{resp.StatusCode, string(body), parseRetryAfter(resp.Header.Get("Retry-After"))}
+	}
+
+	if err := json.Unmarshal(body, target); err != nil {
+		return fmt.Errorf("failed to unmarshal response: %w", err)
+	}
+	return nil
+}
+
+func parseRetryAfter(value string) time.Duration {
+	if value == "" {
+		return 0
+	}
+	if seconds, err := strconv.Atoi(value); err == nil {
+		return time.Duration(seconds) * time.Second
+	}
+	if date, err := http.ParseTime(value); err == nil {
+		return time.Until(date)
+	}
+	return 0
+}
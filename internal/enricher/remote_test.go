@@ -0,0 +1,81 @@
+package enricher
+
+import (
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestDelay(t *testing.T) {
+	r := remote{backoff: 100 * time.Millisecond, maxBackoff: time.Second}
+
+	tests := []struct {
+		attempt int
+		err     error
+		min     time.Duration
+		max     time.Duration
+	}{
+		{1, nil, 1, 100 * time.Millisecond},
+		{2, nil, 1, 200 * time.Millisecond},
+		{3, nil, 1, 400 * time.Millisecond},
+		{5, nil, 1, time.Second},
+		{70, nil, 1, time.Second},
+		{1, &statusError{code: http.StatusTooManyRequests, retryAfter: 3 * time.Second}, 3 * time.Second, 3 * time.Second},
+		{1, &statusError{code: http.StatusTooManyRequests, retryAfter: time.Nanosecond}, 1, 100 * time.Millisecond},
+	}
+
+	for _, tt := range tests {
+		for i := 0; i < 100; i++ {
+			if delay := r.delay(tt.attempt, tt.err); delay < tt.min || delay > tt.max {
+				t.Fatalf("delay(%d, %v) = %s, want within [%s, %s]", tt.attempt, tt.err, delay, tt.min, tt.max)
+			}
+		}
+	}
+}
+
+func TestDelayWithoutBackoff(t *testing.T) {
+	r := remote{}
+	if delay := r.delay(1, nil); delay != 0 {
+		t.Errorf("delay = %s, want 0", delay)
+	}
+}
+
+func TestParseRetryAfter(t *testing.T) {
+	tests := []struct {
+		value string
+		min   time.Duration
+		max   time.Duration
+	}{
+		{"", 0, 0},
+		{"0", 0, 0},
+		{"120", 2 * time.Minute, 2 * time.Minute},
+		{"soon", 0, 0},
+		{time.Now().Add(time.Minute).UTC().Format(http.TimeFormat), 58 * time.Second, time.Minute},
+		{"Sun, 06 Nov 1994 08:49:37 GMT", -1 << 63, 0},
+	}
+
+	for _, tt := range tests {
+		if got := parseRetryAfter(tt.value); got < tt.min || got > tt.max {
+			t.Errorf("parseRetryAfter(%q) = %s, want within [%s, %s]", tt.value, got, tt.min, tt.max)
+		}
+	}
+}
+
+func TestStatusErrorRetryable(t *testing.T) {
+	tests := []struct {
+		code int
+		want bool
+	}{
+		{http.StatusTooManyRequests, true},
+		{http.StatusInternalServerError, true},
+		{http.StatusServiceUnavailable, true},
+		{http.StatusBadRequest, false},
+		{http.StatusUnauthorized, false},
+	}
+
+	for _, tt := range tests {
+		if got := (&statusError{code: tt.code}).retryable(); got != tt.want {
+			t.Errorf("retryable(%d) = %v, want %v", tt.code, got, tt.want)
+		}
+	}
+}
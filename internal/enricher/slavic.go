@@ -0,0 +1,118 @@
+package enricher
+
+import (
+	"context"
+	"github.com/OksidGen/enrich_server/internal/entity"
+	"github.com/rs/zerolog/log"
+	"strings"
+)
+
+const (
+	SourcePatronymic = "patronymic"
+	SourceSurname    = "surname"
+
+	patronymicProbability = 0.99
+	surnameProbability    = 0.9
+)
+
+type genderSuffix struct {
+	suffix string
+	gender string
+}
+
+// Longer suffixes go first so that -ична is not taken for -ич. Latin endings
+// are limited to those that do not occur in common non-Slavic names, so there
+// is no Latin -ich, -in or -ev.
+var patronymicSuffixes = []genderSuffix{
+	{"ichna", "female"}, {"ovna", "female"}, {"evna", "female"}, {"kyzy", "female"},
+	{"ovich", "male"}, {"evich", "male"}, {"ogly", "male"},
+	{"ична", "female"}, {"овна", "female"}, {"евна", "female"}, {"кызы", "female"},
+	{"ович", "male"}, {"евич", "male"}, {"оглы", "male"}, {"ич", "male"},
+}
+
+var surnameSuffixes = []genderSuffix{
+	{"skaya", "female"}, {"ova", "female"},
+	{"skiy", "male"}, {"skii", "male"}, {"skij", "male"}, {"ov", "male"},
+	{"ская", "female"}, {"цкая", "female"}, {"ова", "female"}, {"ева", "female"}, {"ёва", "female"}, {"ина", "female"}, {"ына", "female"},
+	{"ский", "male"}, {"цкий", "male"}, {"ов", "male"}, {"ев", "male"}, {"ёв", "male"}, {"ин", "male"}, {"ын", "male"},
+}
+
+func matchSuffix(value string, suffixes []genderSuffix) (string, bool) {
+	value = strings.ToLower(strings.TrimSpace(value))
+	if value == "" {
+		return "", false
+	}
+	for _, candidate := range suffixes {
+		if strings.HasSuffix(value, candidate.suffix) && len(value) > len(candidate.suffix) {
+			return candidate.gender, true
+		}
+	}
+	return "", false
+}
+
+type slavicGender struct{}
+
+// NewSlavicGender infers gender from patronymic and surname endings, in Latin
+// or Cyrillic. The patronymic is trusted over the surname.
+func NewSlavicGender() Provider {
+	return slavicGender{}
+}
+
+func (slavicGender) Name() string {
+	return "slavic"
+}
+
+func (slavicGender) Attribute() string {
+	return AttributeGender
+}
+
+func (slavicGender) Lookup(ctx context.Context, person entity.Person) (Result, error) {
+	if gender, ok := matchSuffix(person.Patronymic, patronymicSuffixes); ok {
+		return Result{Attribute: AttributeGender, Value: gender, Probability: patronymicProbability, Source: SourcePatronymic}, nil
+	}
+	if gender, ok := matchSuffix(person.Surname, surnameSuffixes); ok {
+		return Result{Attribute: AttributeGender, Value: gender, Probability: surnameProbability, Source: SourceSurname}, nil
+	}
+	return Result{}, ErrNoData
+}
+
+type crossChecked struct {
+	Provider
+	rules Provider
+}
+
+// CrossChecked asks both provider and rules and keeps the more confident
+// answer. Result.Source tells which of them won.
+func CrossChecked(provider, rules Provider) Provider {
+	return &crossChecked{provider, rules}
+}
+
+func (p *crossChecked) Lookup(ctx context.Context, person entity.Person) (Result, error) {
+	ruled, ruleErr := p.rules.Lookup(ctx, person)
+	result, err := p.Provider.Lookup(ctx, person)
+	if ruleErr != nil {
+		return result, err
+	}
+	if err != nil {
+		return ruled, nil
+	}
+	if result.Source == "" {
+		result.Source = p.Provider.Name()
+	}
+
+	if result.Value != ruled.Value {
+		log.Warn().
+			Str("name", person.Name).
+			Interface(result.Source, result.Value).
+			Interface(ruled.Source, ruled.Value).
+			Msg("Gender sources disagree")
+	}
+	if result.Probability > ruled.Probability {
+		return result, nil
+	}
+	return ruled, nil
+}
+
+func (p *crossChecked) Status() ProviderStatus {
+	return statusOf(p.Provider)
+}
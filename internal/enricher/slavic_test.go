@@ -0,0 +1,87 @@
+package enricher
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/OksidGen/enrich_server/internal/entity"
+)
+
+func TestMatchSuffix(t *testing.T) {
+	tests := []struct {
+		value    string
+		suffixes []genderSuffix
+		gender   string
+	}{
+		{"Vasilevich", patronymicSuffixes, "male"},
+		{"Ivanovich", patronymicSuffixes, "male"},
+		{"Petrovna", patronymicSuffixes, "female"},
+		{"Sergeevna", patronymicSuffixes, "female"},
+		{"Ilyichna", patronymicSuffixes, "female"},
+		{"Mamed ogly", patronymicSuffixes, "male"},
+		{"Ali kyzy", patronymicSuffixes, "female"},
+		{"Ильич", patronymicSuffixes, "male"},
+		{"Ильинична", patronymicSuffixes, "female"},
+		{"Ивановна", patronymicSuffixes, "female"},
+		{"Heinrich", patronymicSuffixes, ""},
+		{"Ulrich", patronymicSuffixes, ""},
+
+		{"Ushakov", surnameSuffixes, "male"},
+		{"Ushakova", surnameSuffixes, "female"},
+		{"Kovalskaya", surnameSuffixes, "female"},
+		{"Kovalskiy", surnameSuffixes, "male"},
+		{"Kovalskii", surnameSuffixes, "male"},
+		{"Kovalskij", surnameSuffixes, "male"},
+		{"Ушаков", surnameSuffixes, "male"},
+		{"Ушакова", surnameSuffixes, "female"},
+		{"Соловьёв", surnameSuffixes, "male"},
+		{"Соловьёва", surnameSuffixes, "female"},
+		{"Пушкин", surnameSuffixes, "male"},
+		{"Пушкина", surnameSuffixes, "female"},
+		{"Достоевский", surnameSuffixes, "male"},
+		{"Троцкая", surnameSuffixes, "female"},
+		{"Medina", surnameSuffixes, ""},
+		{"Molina", surnameSuffixes, ""},
+		{"Messina", surnameSuffixes, ""},
+		{"Martin", surnameSuffixes, ""},
+		{"Franklin", surnameSuffixes, ""},
+		{"Villanueva", surnameSuffixes, ""},
+		{"Yevtushenko", surnameSuffixes, ""},
+		{"Ov", surnameSuffixes, ""},
+		{"ов", surnameSuffixes, ""},
+		{"", surnameSuffixes, ""},
+	}
+
+	for _, tt := range tests {
+		gender, ok := matchSuffix(tt.value, tt.suffixes)
+		if ok != (tt.gender != "") || gender != tt.gender {
+			t.Errorf("matchSuffix(%q) = %q, %v; want %q", tt.value, gender, ok, tt.gender)
+		}
+	}
+}
+
+func TestSlavicGenderPrefersPatronymic(t *testing.T) {
+	tests := []struct {
+		person entity.Person
+		gender string
+		source string
+	}{
+		{entity.Person{Surname: "Ushakova", Patronymic: "Ivanovich"}, "male", SourcePatronymic},
+		{entity.Person{Surname: "Ushakova"}, "female", SourceSurname},
+		{entity.Person{Surname: "Medina", Patronymic: "Maria"}, "", ""},
+	}
+
+	for _, tt := range tests {
+		result, err := NewSlavicGender().Lookup(context.Background(), tt.person)
+		if tt.gender == "" {
+			if !errors.Is(err, ErrNoData) {
+				t.Errorf("Lookup(%+v) error = %v, want ErrNoData", tt.person, err)
+			}
+			continue
+		}
+		if err != nil || result.Value != tt.gender || result.Source != tt.source {
+			t.Errorf("Lookup(%+v) = %v from %s, %v; want %s from %s", tt.person, result.Value, result.Source, err, tt.gender, tt.source)
+		}
+	}
+}
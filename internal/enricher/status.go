@@ -0,0 +1,37 @@
+package enricher
+
+type ProviderStatus struct {
+	Name      string         `json:"name"`
+	Attribute string         `json:"attribute"`
+	Cache     *CacheStatus   `json:"cache,omitempty"`
+	Breaker   *BreakerStatus `json:"breaker,omitempty"`
+}
+
+type CacheStatus struct {
+	Hits   uint64 `json:"hits"`
+	Misses uint64 `json:"misses"`
+}
+
+// Reporter is implemented by enrichers that can describe their providers.
+type Reporter interface {
+	Status() []ProviderStatus
+}
+
+type statusReporter interface {
+	Status() ProviderStatus
+}
+
+func statusOf(provider Provider) ProviderStatus {
+	if reporter, ok := provider.(statusReporter); ok {
+		return reporter.Status()
+	}
+	return ProviderStatus{Name: provider.Name(), Attribute: provider.Attribute()}
+}
+
+func (c *Chain) Status() []ProviderStatus {
+	statuses := make([]ProviderStatus, 0, len(c.providers))
+	for _, provider := range c.providers {
+		statuses = append(statuses, statusOf(provider))
+	}
+	return statuses
+}
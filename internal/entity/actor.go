@@ -0,0 +1,19 @@
+package entity
+
+import "context"
+
+// ActorWorker identifies changes made by the background enrichment workers.
+const ActorWorker = "enrichment-worker"
+
+type actorKey struct{}
+
+// WithActor returns a copy of ctx that attributes changes to actor.
+func WithActor(ctx context.Context, actor string) context.Context {
+	return context.WithValue(ctx, actorKey{}, actor)
+}
+
+// ActorFrom returns the actor responsible for changes made with ctx.
+func ActorFrom(ctx context.Context) string {
+	actor, _ := ctx.Value(actorKey{}).(string)
+	return actor
+}
@@ -0,0 +1,58 @@
+package entity
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Attributes is stored as a PostgreSQL TEXT[] column. pgx encodes it through
+// the underlying []string, so only scanning has to be implemented.
+type Attributes []string
+
+func (a *Attributes) Scan(src interface{}) error {
+	var literal string
+	switch src := src.(type) {
+	case nil:
+		*a = nil
+		return nil
+	case string:
+		literal = src
+	case []byte:
+		literal = string(src)
+	default:
+		return fmt.Errorf("cannot scan %T into Attributes", src)
+	}
+
+	literal = strings.TrimSuffix(strings.TrimPrefix(literal, "{"), "}")
+	if literal == "" {
+		*a = Attributes{}
+		return nil
+	}
+
+	values := strings.Split(literal, ",")
+	for i, value := range values {
+		values[i] = strings.Trim(value, `"`)
+	}
+	*a = values
+	return nil
+}
+
+func (a Attributes) Contains(attribute string) bool {
+	for _, value := range a {
+		if value == attribute {
+			return true
+		}
+	}
+	return false
+}
+
+// Without returns a copy of a without attribute.
+func (a Attributes) Without(attribute string) Attributes {
+	result := Attributes{}
+	for _, value := range a {
+		if value != attribute {
+			result = append(result, value)
+		}
+	}
+	return result
+}
@@ -0,0 +1,8 @@
+package entity
+
+// BatchResult is the outcome of a single item of a bulk request. Either ID
+// or Err is set.
+type BatchResult struct {
+	ID  int
+	Err error
+}
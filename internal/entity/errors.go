@@ -0,0 +1,13 @@
+package entity
+
+import "errors"
+
+// Domain errors. Layers wrap them with context; the delivery layer maps them
+// to HTTP statuses.
+var (
+	ErrNotFound            = errors.New("not found")
+	ErrValidation          = errors.New("validation failed")
+	ErrConflict            = errors.New("conflict")
+	ErrPreconditionFailed  = errors.New("precondition failed")
+	ErrUpstreamUnavailable = errors.New("upstream unavailable")
+)
@@ -0,0 +1,24 @@
+package entity
+
+import "time"
+
+const (
+	OperationCreate  = "create"
+	OperationUpdate  = "update"
+	OperationDelete  = "delete"
+	OperationRestore = "restore"
+	// OperationBaseline is the state of a person that existed before history
+	// was recorded, taken when recording started.
+	OperationBaseline = "baseline"
+)
+
+// PersonRevision is the state of a person right after a change.
+type PersonRevision struct {
+	ID        int64     `json:"id" db:"id"`
+	PersonID  int       `json:"person_id" db:"person_id"`
+	Operation string    `json:"operation" db:"operation"`
+	Version   int       `json:"version" db:"version"`
+	ChangedAt time.Time `json:"changed_at" db:"changed_at"`
+	ChangedBy string    `json:"changed_by,omitempty" db:"changed_by"`
+	Person    Person    `json:"person" db:"-"`
+}
@@ -0,0 +1,13 @@
+package entity
+
+const (
+	EnrichmentPending = "pending"
+	EnrichmentDone    = "done"
+	EnrichmentFailed  = "failed"
+)
+
+type EnrichmentJob struct {
+	ID       int64 `db:"id"`
+	PersonID int   `db:"person_id"`
+	Attempts int   `db:"attempts"`
+}
@@ -1,61 +1,34 @@
 package entity
 
-import "fmt"
+import "time"
 
 type Person struct {
-	ID          int    `json:"id"`
-	Name        string `json:"name"`
-	Surname     string `json:"surname"`
-	Patronymic  string `json:"patronymic,omitempty"`
-	Age         int    `json:"age,omitempty"`
-	Gender      string `json:"gender,omitempty"`
-	Nationality string `json:"nationality,omitempty"`
+	ID                     int                    `json:"id"`
+	Name                   string                 `json:"name"`
+	Surname                string                 `json:"surname"`
+	Patronymic             string                 `json:"patronymic,omitempty"`
+	Age                    *int                   `json:"age"`
+	AgeCount               int                    `json:"age_count,omitempty" db:"age_count"`
+	Gender                 *string                `json:"gender"`
+	GenderProbability      float64                `json:"gender_probability,omitempty" db:"gender_probability"`
+	GenderSource           string                 `json:"gender_source,omitempty" db:"gender_source"`
+	Nationality            *string                `json:"nationality"`
+	NationalityProbability float64                `json:"nationality_probability,omitempty" db:"nationality_probability"`
+	Nationalities          []NationalityCandidate `json:"nationalities,omitempty" db:"-"`
+	CountryID              string                 `json:"country_id,omitempty" db:"country_id"`
+	EnrichmentStatus       string                 `json:"enrichment_status,omitempty" db:"enrichment_status"`
+	PendingAttributes      Attributes             `json:"pending_attributes,omitempty" db:"pending_attributes"`
+	LockedAttributes       Attributes             `json:"locked_attributes,omitempty" db:"locked_attributes"`
+	Version                int                    `json:"version" db:"version"`
+	DeletedAt              *time.Time             `json:"deleted_at,omitempty" db:"deleted_at"`
+	CreatedAt              time.Time              `json:"created_at" db:"created_at"`
+	CreatedBy              string                 `json:"created_by,omitempty" db:"created_by"`
+	UpdatedAt              time.Time              `json:"updated_at" db:"updated_at"`
+	UpdatedBy              string                 `json:"updated_by,omitempty" db:"updated_by"`
+	Provenance             []Provenance           `json:"-" db:"-"`
 }
 
-func (person *Person) MapToPerson(data map[string]interface{}) error {
-
-	for key, value := range data {
-		switch key {
-		case "name":
-			if name, ok := value.(string); ok {
-				person.Name = name
-			} else {
-				return fmt.Errorf("Ошибка в поле 'name'")
-			}
-		case "surname":
-			if surname, ok := value.(string); ok {
-				person.Surname = surname
-			} else {
-				return fmt.Errorf("Ошибка в поле 'surname'")
-			}
-		case "patronymic":
-			if patronymic, ok := value.(string); ok {
-				person.Patronymic = patronymic
-			} else {
-				return fmt.Errorf("Ошибка в поле 'patronymic'")
-			}
-		case "age":
-			if age, ok := value.(int); ok {
-				person.Age = age
-			} else {
-				return fmt.Errorf("Ошибка в поле 'age'")
-			}
-		case "gender":
-			if gender, ok := value.(string); ok {
-				person.Gender = gender
-			} else {
-				return fmt.Errorf("Ошибка в поле 'gender'")
-			}
-		case "nationality":
-			if nationality, ok := value.(string); ok {
-				person.Nationality = nationality
-			} else {
-				return fmt.Errorf("Ошибка в поле 'nationality'")
-			}
-		default:
-			return fmt.Errorf("Недопустимое поле: %s", key)
-		}
-	}
-
-	return nil
+type NationalityCandidate struct {
+	CountryID   string  `json:"country_id" db:"country_id"`
+	Probability float64 `json:"probability" db:"probability"`
 }
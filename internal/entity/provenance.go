@@ -0,0 +1,19 @@
+package entity
+
+import "time"
+
+const (
+	SourceInput   = "input"
+	SourceDefault = "default"
+	SourceManual  = "manual"
+)
+
+type Provenance struct {
+	ID           int64     `json:"id" db:"id"`
+	PersonID     int       `json:"person_id" db:"person_id"`
+	Field        string    `json:"field" db:"field"`
+	Value        string    `json:"value" db:"value"`
+	Source       string    `json:"source" db:"source"`
+	ResponseHash string    `json:"response_hash,omitempty" db:"response_hash"`
+	RecordedAt   time.Time `json:"recorded_at" db:"recorded_at"`
+}
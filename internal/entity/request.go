@@ -0,0 +1,189 @@
+package entity
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+)
+
+// Patch formats accepted for partial updates.
+const (
+	MergePatch = "merge-patch"
+	JSONPatch  = "json-patch"
+)
+
+// PersonRequest is the body of create and update requests. Nil fields were
+// not supplied by the client.
+type PersonRequest struct {
+	Name             *string   `json:"name"`
+	Surname          *string   `json:"surname"`
+	Patronymic       *string   `json:"patronymic"`
+	Age              *int      `json:"age"`
+	Gender           *string   `json:"gender"`
+	Nationality      *string   `json:"nationality"`
+	CountryID        *string   `json:"country_id"`
+	LockedAttributes *[]string `json:"locked_attributes"`
+
+	decodeErrors ValidationError
+}
+
+// Fields returns the supplied fields keyed by column name.
+func (r PersonRequest) Fields() map[string]interface{} {
+	fields := make(map[string]interface{})
+	if r.Name != nil {
+		fields["name"] = *r.Name
+	}
+	if r.Surname != nil {
+		fields["surname"] = *r.Surname
+	}
+	if r.Patronymic != nil {
+		fields["patronymic"] = *r.Patronymic
+	}
+	if r.Age != nil {
+		fields["age"] = *r.Age
+	}
+	if r.Gender != nil {
+		fields["gender"] = *r.Gender
+	}
+	if r.Nationality != nil {
+		fields["nationality"] = *r.Nationality
+	}
+	if r.CountryID != nil {
+		fields["country_id"] = *r.CountryID
+	}
+	if r.LockedAttributes != nil {
+		fields["locked_attributes"] = r.lockedAttributes()
+	}
+	return fields
+}
+
+// Person returns a person with the supplied fields of r.
+func (r PersonRequest) Person() Person {
+	var person Person
+	if r.Name != nil {
+		person.Name = *r.Name
+	}
+	if r.Surname != nil {
+		person.Surname = *r.Surname
+	}
+	if r.Patronymic != nil {
+		person.Patronymic = *r.Patronymic
+	}
+	person.Age = r.Age
+	person.Gender = r.Gender
+	person.Nationality = r.Nationality
+	if r.CountryID != nil {
+		person.CountryID = *r.CountryID
+	}
+	if r.LockedAttributes != nil {
+		person.LockedAttributes = r.lockedAttributes()
+	}
+	return person
+}
+
+// lockedAttributes returns the supplied locks without duplicates.
+func (r PersonRequest) lockedAttributes() Attributes {
+	locked := Attributes{}
+	for _, attribute := range *r.LockedAttributes {
+		if !locked.Contains(attribute) {
+			locked = append(locked, attribute)
+		}
+	}
+	return locked
+}
+
+// DecodePersonRequest decodes a JSON body into req. An empty body yields an
+// empty request. Only a body that is not a JSON object is an error. Unknown
+// fields and values of the wrong type are kept in req and reported by
+// validation together with every other violation.
+func DecodePersonRequest(r io.Reader, req *PersonRequest) error {
+	return decodeRequest(r, req, nil)
+}
+
+// readOnlyFields are the fields of a person returned by GET /people/:id that
+// cannot be changed by a request.
+var readOnlyFields = map[string]bool{
+	"id": true, "age_count": true, "gender_probability": true, "gender_source": true,
+	"nationality_probability": true, "nationalities": true, "enrichment_status": true,
+	"pending_attributes": true, "version": true, "deleted_at": true,
+	"created_at": true, "created_by": true, "updated_at": true, "updated_by": true,
+}
+
+// DecodePersonReplacement is DecodePersonRequest for full replacements. It
+// also accepts the read-only fields of a person and ignores them, so that a
+// fetched person can be edited and sent back as is.
+func DecodePersonReplacement(r io.Reader, req *PersonRequest) error {
+	return decodeRequest(r, req, readOnlyFields)
+}
+
+// DecodeViolations returns the problems found while decoding the request.
+func (r PersonRequest) DecodeViolations() []Violation {
+	return r.decodeErrors.Violations
+}
+
+// Invalid reports whether field could not be decoded.
+func (r PersonRequest) Invalid(field string) bool {
+	for _, violation := range r.decodeErrors.Violations {
+		if violation.Field == field {
+			return true
+		}
+	}
+	return false
+}
+
+func decodeRequest(r io.Reader, req *PersonRequest, ignored map[string]bool) error {
+	var body map[string]json.RawMessage
+	err := json.NewDecoder(r).Decode(&body)
+	if errors.Is(err, io.EOF) {
+		return nil
+	}
+	var typeErr *json.UnmarshalTypeError
+	if errors.As(err, &typeErr) {
+		return fmt.Errorf("%w: request body must be a JSON object", ErrValidation)
+	}
+	if err != nil {
+		return fmt.Errorf("%w: malformed request body: %v", ErrValidation, err)
+	}
+
+	fields := []struct {
+		name   string
+		target interface{}
+	}{
+		{"name", &req.Name},
+		{"surname", &req.Surname},
+		{"patronymic", &req.Patronymic},
+		{"age", &req.Age},
+		{"gender", &req.Gender},
+		{"nationality", &req.Nationality},
+		{"country_id", &req.CountryID},
+		{"locked_attributes", &req.LockedAttributes},
+	}
+	for _, field := range fields {
+		value, ok := body[field.name]
+		if !ok {
+			continue
+		}
+		delete(body, field.name)
+
+		var typeErr *json.UnmarshalTypeError
+		if err := json.Unmarshal(value, field.target); errors.As(err, &typeErr) {
+			req.decodeErrors.Add(field.name, ViolationType, "must be %s", typeErr.Type)
+		} else if err != nil {
+			req.decodeErrors.Add(field.name, ViolationType, "is invalid")
+		}
+	}
+
+	unknown := make([]string, 0, len(body))
+	for name := range body {
+		if !ignored[name] {
+			unknown = append(unknown, name)
+		}
+	}
+	sort.Strings(unknown)
+	for _, name := range unknown {
+		req.decodeErrors.Add(name, ViolationUnknown, "is not allowed")
+	}
+	return nil
+}
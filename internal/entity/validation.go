@@ -0,0 +1,56 @@
+package entity
+
+import (
+	"fmt"
+	"strings"
+)
+
+const (
+	ViolationRequired = "required"
+	ViolationEmpty    = "empty"
+	ViolationTooLong  = "too_long"
+	ViolationRange    = "out_of_range"
+	ViolationEnum     = "not_allowed"
+	ViolationType     = "invalid_type"
+	ViolationUnknown  = "unknown_field"
+)
+
+// Violation describes a single field that failed validation.
+type Violation struct {
+	Field   string `json:"field"`
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+// ValidationError lists every violation found in a request.
+type ValidationError struct {
+	Violations []Violation `json:"violations"`
+}
+
+func (e *ValidationError) Add(field, code, format string, args ...interface{}) {
+	e.Violations = append(e.Violations, Violation{
+		Field:   field,
+		Code:    code,
+		Message: fmt.Sprintf(format, args...),
+	})
+}
+
+// Err returns e if it holds any violations and nil otherwise.
+func (e *ValidationError) Err() error {
+	if len(e.Violations) == 0 {
+		return nil
+	}
+	return e
+}
+
+func (e *ValidationError) Error() string {
+	messages := make([]string, 0, len(e.Violations))
+	for _, v := range e.Violations {
+		messages = append(messages, fmt.Sprintf("%s: %s", v.Field, v.Message))
+	}
+	return ErrValidation.Error() + ": " + strings.Join(messages, "; ")
+}
+
+func (e *ValidationError) Is(target error) bool {
+	return target == ErrValidation
+}
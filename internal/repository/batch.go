@@ -0,0 +1,169 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"github.com/OksidGen/enrich_server/internal/entity"
+	"github.com/jmoiron/sqlx"
+	"github.com/rs/zerolog/log"
+	"strings"
+)
+
+// maxParams is the PostgreSQL limit of bind parameters per statement.
+const maxParams = 65535
+
+// CreatePeople inserts people with multi-row inserts in a single transaction
+// and returns their results in the same order. If the rows are rejected by a
+// constraint, people are inserted one by one so that only the offending ones
+// fail.
+func (r *postgresRepository) CreatePeople(ctx context.Context, people []entity.Person) ([]entity.BatchResult, error) {
+	log.Debug().Int("count", len(people)).Msg("Calling CreatePeople repository")
+
+	if len(people) == 0 {
+		return nil, nil
+	}
+
+	tx, err := r.db.BeginTxx(ctx, nil)
+	if err != nil {
+		log.Err(err).Msg("Failed to begin transaction")
+		return nil, dbError(err)
+	}
+	defer rollback(tx)
+
+	var ids []int
+	err = tx.SelectContext(ctx, &ids, "SELECT nextval(pg_get_serial_sequence('people', 'id')) FROM generate_series(1, $1)", len(people))
+	if err != nil {
+		log.Err(err).Msg("Failed to allocate person ids")
+		return nil, dbError(err)
+	}
+
+	results := make([]entity.BatchResult, len(people))
+	err = withSavepoint(ctx, tx, func() error {
+		return insertPeople(ctx, tx, ids, people)
+	})
+	switch {
+	case err == nil:
+		for i, id := range ids {
+			results[i].ID = id
+		}
+	case rowError(err):
+		log.Warn().Err(err).Msg("Batch insert was rejected, inserting people one by one")
+		for i := range people {
+			err := withSavepoint(ctx, tx, func() error {
+				return insertPeople(ctx, tx, ids[i:i+1], people[i:i+1])
+			})
+			if err != nil && !rowError(err) {
+				return nil, dbError(err)
+			}
+			if err != nil {
+				results[i].Err = dbError(err)
+				continue
+			}
+			results[i].ID = ids[i]
+		}
+	default:
+		return nil, dbError(err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		log.Err(err).Msg("Failed to commit transaction")
+		return nil, dbError(err)
+	}
+	return results, nil
+}
+
+// insertPeople inserts people under the given ids together with their
+// nationalities, provenance and enrichment jobs.
+func insertPeople(ctx context.Context, tx sqlx.ExecerContext, ids []int, people []entity.Person) error {
+	actor := entity.ActorFrom(ctx)
+	var rows, nationalities, provenance, jobs [][]interface{}
+	for i, person := range people {
+		id := ids[i]
+		rows = append(rows, []interface{}{id, person.Name, person.Surname, person.Patronymic, person.Age, person.AgeCount,
+			person.Gender, person.GenderProbability, person.GenderSource, person.Nationality, person.NationalityProbability,
+			person.CountryID, person.EnrichmentStatus, person.PendingAttributes, person.LockedAttributes, actor, actor})
+		for _, candidate := range person.Nationalities {
+			nationalities = append(nationalities, []interface{}{id, candidate.CountryID, candidate.Probability})
+		}
+		for _, record := range person.Provenance {
+			provenance = append(provenance, []interface{}{id, record.Field, record.Value, record.Source, record.ResponseHash})
+		}
+		if person.EnrichmentStatus == entity.EnrichmentPending {
+			jobs = append(jobs, []interface{}{id})
+		}
+	}
+
+	for _, insert := range []struct {
+		table      string
+		rows       [][]interface{}
+		onConflict string
+	}{
+		{`people (id, name, surname, patronymic, age, age_count, gender, gender_probability, gender_source,
+			nationality, nationality_probability, country_id, enrichment_status, pending_attributes, locked_attributes,
+			created_by, updated_by)`, rows, ""},
+		// Providers may list a country more than once.
+		{"person_nationalities (person_id, country_id, probability)", nationalities, "ON CONFLICT DO NOTHING"},
+		{"person_provenance (person_id, field, value, source, response_hash)", provenance, ""},
+		{"enrichment_jobs (person_id)", jobs, ""},
+	} {
+		if err := insertRows(ctx, tx, insert.table, insert.rows, insert.onConflict); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// withSavepoint runs fn inside a savepoint of tx and rolls back to it if fn
+// fails, so that the transaction stays usable.
+func withSavepoint(ctx context.Context, tx *sqlx.Tx, fn func() error) error {
+	if _, err := tx.ExecContext(ctx, "SAVEPOINT batch"); err != nil {
+		log.Err(err).Msg("Failed to create savepoint")
+		return err
+	}
+	if err := fn(); err != nil {
+		if _, rollbackErr := tx.ExecContext(ctx, "ROLLBACK TO SAVEPOINT batch"); rollbackErr != nil {
+			log.Err(rollbackErr).Msg("Failed to roll back to savepoint")
+			return rollbackErr
+		}
+		return err
+	}
+	if _, err := tx.ExecContext(ctx, "RELEASE SAVEPOINT batch"); err != nil {
+		log.Err(err).Msg("Failed to release savepoint")
+		return err
+	}
+	return nil
+}
+
+// insertRows inserts rows into table with as few multi-row statements as the
+// bind parameter limit allows. onConflict is appended to every statement.
+func insertRows(ctx context.Context, tx sqlx.ExecerContext, table string, rows [][]interface{}, onConflict string) error {
+	if len(rows) == 0 {
+		return nil
+	}
+
+	chunk := maxParams / len(rows[0])
+	for start := 0; start < len(rows); start += chunk {
+		end := start + chunk
+		if end > len(rows) {
+			end = len(rows)
+		}
+
+		var values []string
+		var args []interface{}
+		for _, row := range rows[start:end] {
+			placeholders := make([]string, len(row))
+			for i, value := range row {
+				args = append(args, value)
+				placeholders[i] = fmt.Sprintf("$%d", len(args))
+			}
+			values = append(values, "("+strings.Join(placeholders, ", ")+")")
+		}
+
+		_, err := tx.ExecContext(ctx, "INSERT INTO "+table+" VALUES "+strings.Join(values, ", ")+" "+onConflict, args...)
+		if err != nil {
+			log.Err(err).Str("table", table).Int("rows", end-start).Msg("Failed to insert rows")
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,52 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"github.com/jmoiron/sqlx"
+	"github.com/rs/zerolog/log"
+	"time"
+)
+
+type CacheRepository interface {
+	GetCachedResult(ctx context.Context, provider, name string) ([]byte, bool, error)
+	SetCachedResult(ctx context.Context, provider, name string, payload []byte, expiresAt time.Time) error
+}
+
+type postgresCacheRepository struct {
+	db *sqlx.DB
+}
+
+func NewPostgresCacheRepository(db *sqlx.DB) CacheRepository {
+	return &postgresCacheRepository{db}
+}
+
+func (r *postgresCacheRepository) GetCachedResult(ctx context.Context, provider, name string) ([]byte, bool, error) {
+	var payload []byte
+	err := r.db.QueryRowContext(ctx, `
+		SELECT payload FROM name_enrichment_cache
+		WHERE provider = $1 AND name = $2 AND expires_at > now()
+	`, provider, name).Scan(&payload)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, false, nil
+	}
+	if err != nil {
+		log.Err(err).Str("provider", provider).Str("name", name).Msg("Failed to get cached result")
+		return nil, false, err
+	}
+	return payload, true, nil
+}
+
+func (r *postgresCacheRepository) SetCachedResult(ctx context.Context, provider, name string, payload []byte, expiresAt time.Time) error {
+	_, err := r.db.ExecContext(ctx, `
+		INSERT INTO name_enrichment_cache (provider, name, payload, expires_at)
+		VALUES ($1, $2, $3, $4)
+		ON CONFLICT (provider, name) DO UPDATE SET payload = EXCLUDED.payload, expires_at = EXCLUDED.expires_at
+	`, provider, name, payload, expiresAt)
+	if err != nil {
+		log.Err(err).Str("provider", provider).Str("name", name).Msg("Failed to set cached result")
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,63 @@
+package repository
+
+import (
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"github.com/OksidGen/enrich_server/internal/entity"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/rs/zerolog/log"
+	"net"
+	"strings"
+)
+
+const (
+	pgUniqueViolation     = "23505"
+	pgForeignKeyViolation = "23503"
+
+	// Error classes, the first two characters of a code.
+	pgDataException      = "22"
+	pgIntegrityViolation = "23"
+)
+
+// dbError translates database errors into domain errors.
+func dbError(err error) error {
+	if err == nil {
+		return nil
+	}
+
+	var pgErr *pgconn.PgError
+	var connErr *pgconn.ConnectError
+	var netErr net.Error
+	switch {
+	case errors.Is(err, sql.ErrNoRows):
+		return fmt.Errorf("%w: %w", entity.ErrNotFound, err)
+	case errors.As(err, &pgErr) && (pgErr.Code == pgUniqueViolation || pgErr.Code == pgForeignKeyViolation):
+		// Driver messages name tables and constraints, so clients only get
+		// a fixed message.
+		logRejected(pgErr)
+		return fmt.Errorf("%w: the data conflicts with existing records", entity.ErrConflict)
+	case errors.As(err, &pgErr) && strings.HasPrefix(pgErr.Code, pgDataException):
+		logRejected(pgErr)
+		return fmt.Errorf("%w: a value is not accepted by the database", entity.ErrValidation)
+	case errors.As(err, &connErr), errors.As(err, &netErr), errors.Is(err, driver.ErrBadConn), errors.Is(err, sql.ErrConnDone):
+		return fmt.Errorf("%w: %w", entity.ErrUpstreamUnavailable, err)
+	}
+	return err
+}
+
+func logRejected(pgErr *pgconn.PgError) {
+	log.Warn().Err(pgErr).Str("code", pgErr.Code).Str("table", pgErr.TableName).
+		Str("constraint", pgErr.ConstraintName).Msg("Database rejected the data")
+}
+
+// rowError reports whether err was caused by the data of a row rather than
+// by the database or the connection.
+func rowError(err error) bool {
+	var pgErr *pgconn.PgError
+	if !errors.As(err, &pgErr) {
+		return false
+	}
+	return strings.HasPrefix(pgErr.Code, pgDataException) || strings.HasPrefix(pgErr.Code, pgIntegrityViolation)
+}
@@ -0,0 +1,80 @@
+package repository
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/OksidGen/enrich_server/internal/entity"
+	"github.com/rs/zerolog/log"
+	"time"
+)
+
+const historyColumns = "id, person_id, operation, version, changed_at, changed_by, data"
+
+type historyRow struct {
+	entity.PersonRevision
+	Data []byte `db:"data"`
+}
+
+func (row historyRow) revision() (entity.PersonRevision, error) {
+	revision := row.PersonRevision
+	if err := json.Unmarshal(row.Data, &revision.Person); err != nil {
+		log.Err(err).Int64("revision", row.ID).Msg("Failed to decode person revision")
+		return entity.PersonRevision{}, err
+	}
+	return revision, nil
+}
+
+func (r *postgresRepository) GetHistory(ctx context.Context, id int) ([]entity.PersonRevision, error) {
+	log.Debug().Int("id", id).Msg("Calling GetHistory repository")
+
+	var rows []historyRow
+	err := r.db.SelectContext(ctx, &rows, `
+		SELECT `+historyColumns+` FROM people_history
+		WHERE person_id = $1
+		ORDER BY changed_at, id
+	`, id)
+	if err != nil {
+		log.Err(err).Int("id", id).Msg("Failed to get history")
+		return nil, dbError(err)
+	}
+	if len(rows) == 0 {
+		return nil, fmt.Errorf("person %d: %w", id, entity.ErrNotFound)
+	}
+
+	revisions := make([]entity.PersonRevision, 0, len(rows))
+	for _, row := range rows {
+		revision, err := row.revision()
+		if err != nil {
+			return nil, err
+		}
+		revisions = append(revisions, revision)
+	}
+	return revisions, nil
+}
+
+// GetPersonAsOf reconstructs the person as it was at the given moment.
+func (r *postgresRepository) GetPersonAsOf(ctx context.Context, id int, at time.Time) (entity.Person, error) {
+	log.Debug().Int("id", id).Time("at", at).Msg("Calling GetPersonAsOf repository")
+
+	var row historyRow
+	err := r.db.GetContext(ctx, &row, `
+		SELECT `+historyColumns+` FROM people_history
+		WHERE person_id = $1 AND changed_at <= $2
+		ORDER BY changed_at DESC, id DESC
+		LIMIT 1
+	`, id, at)
+	if err != nil {
+		log.Err(err).Int("id", id).Time("at", at).Msg("Failed to get person revision")
+		return entity.Person{}, dbError(err)
+	}
+
+	revision, err := row.revision()
+	if err != nil {
+		return entity.Person{}, err
+	}
+	if revision.Person.DeletedAt != nil {
+		return entity.Person{}, fmt.Errorf("person %d was deleted at %s: %w", id, at.Format(time.RFC3339), entity.ErrNotFound)
+	}
+	return revision.Person, nil
+}
@@ -0,0 +1,203 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/OksidGen/enrich_server/internal/entity"
+	"github.com/jmoiron/sqlx"
+	"github.com/rs/zerolog/log"
+	"time"
+)
+
+func enqueueEnrichment(ctx context.Context, tx sqlx.ExecerContext, personID int) error {
+	_, err := tx.ExecContext(ctx, `
+		INSERT INTO enrichment_jobs (person_id)
+		VALUES ($1)
+		ON CONFLICT (person_id) DO UPDATE SET attempts = 0, run_at = now(), last_error = NULL
+	`, personID)
+	if err != nil {
+		log.Err(err).Int("person_id", personID).Msg("Failed to enqueue enrichment job")
+		return err
+	}
+	return nil
+}
+
+// saveEnrichment writes the enriched attributes of person if it is still at
+// person.Version. Otherwise it fails with entity.ErrConflict and writes nothing.
+// Columns of attributes locked in the stored row are never overwritten.
+func saveEnrichment(ctx context.Context, tx sqlx.ExecerContext, person entity.Person) error {
+	result, err := tx.ExecContext(ctx, `
+		UPDATE people
+		SET age = CASE WHEN 'age' = ANY(locked_attributes) THEN age ELSE $1 END,
+			age_count = CASE WHEN 'age' = ANY(locked_attributes) THEN age_count ELSE $2 END,
+			gender = CASE WHEN 'gender' = ANY(locked_attributes) THEN gender ELSE $3 END,
+			gender_probability = CASE WHEN 'gender' = ANY(locked_attributes) THEN gender_probability ELSE $4 END,
+			gender_source = CASE WHEN 'gender' = ANY(locked_attributes) THEN gender_source ELSE $5 END,
+			nationality = CASE WHEN 'nationality' = ANY(locked_attributes) THEN nationality ELSE $6 END,
+			nationality_probability = CASE WHEN 'nationality' = ANY(locked_attributes) THEN nationality_probability ELSE $7 END,
+			enrichment_status = $8, pending_attributes = $9,
+			version = version + 1, updated_at = now(), updated_by = $10
+		WHERE id = $11 AND version = $12 AND deleted_at IS NULL
+	`, person.Age, person.AgeCount, person.Gender, person.GenderProbability, person.GenderSource,
+		person.Nationality, person.NationalityProbability, person.EnrichmentStatus, person.PendingAttributes,
+		entity.ActorFrom(ctx), person.ID, person.Version)
+	if err != nil {
+		log.Err(err).Int("id", person.ID).Msg("Failed to save enrichment")
+		return err
+	}
+	affected, err := result.RowsAffected()
+	if err != nil {
+		log.Err(err).Int("id", person.ID).Msg("Failed to get affected rows")
+		return err
+	}
+	if affected == 0 {
+		return fmt.Errorf("person %d was modified concurrently: %w", person.ID, entity.ErrConflict)
+	}
+	if err := saveNationalities(ctx, tx, person.ID, person.Nationalities); err != nil {
+		return err
+	}
+	return saveProvenance(ctx, tx, person.ID, person.Provenance)
+}
+
+func (r *postgresRepository) RequestEnrichment(ctx context.Context, people []entity.Person) error {
+	log.Debug().Int("count", len(people)).Msg("Calling RequestEnrichment repository")
+
+	tx, err := r.db.BeginTxx(ctx, nil)
+	if err != nil {
+		log.Err(err).Msg("Failed to begin transaction")
+		return dbError(err)
+	}
+	defer rollback(tx)
+
+	for _, person := range people {
+		result, err := tx.ExecContext(ctx, `
+			UPDATE people
+			SET enrichment_status = $1, pending_attributes = $2, version = version + 1, updated_at = now(), updated_by = $3
+			WHERE id = $4 AND deleted_at IS NULL
+		`, entity.EnrichmentPending, person.PendingAttributes, entity.ActorFrom(ctx), person.ID)
+		if err != nil {
+			log.Err(err).Int("id", person.ID).Msg("Failed to request enrichment")
+			return dbError(err)
+		}
+		affected, err := result.RowsAffected()
+		if err != nil {
+			log.Err(err).Int("id", person.ID).Msg("Failed to get affected rows")
+			return dbError(err)
+		}
+		if affected == 0 {
+			// Deleted since it was read.
+			continue
+		}
+		if err := enqueueEnrichment(ctx, tx, person.ID); err != nil {
+			return dbError(err)
+		}
+	}
+
+	return dbError(tx.Commit())
+}
+
+func (r *postgresRepository) ClaimEnrichmentJob(ctx context.Context, lease time.Duration) (entity.EnrichmentJob, error) {
+	var job entity.EnrichmentJob
+	err := r.db.GetContext(ctx, &job, `
+		UPDATE enrichment_jobs
+		SET attempts = attempts + 1, locked_until = now() + $1::float8 * interval '1 second'
+		WHERE id = (
+			SELECT id FROM enrichment_jobs
+			WHERE run_at <= now() AND (locked_until IS NULL OR locked_until < now())
+			ORDER BY run_at, id
+			LIMIT 1
+			FOR UPDATE SKIP LOCKED
+		)
+		RETURNING id, person_id, attempts
+	`, lease.Seconds())
+	if err != nil {
+		return entity.EnrichmentJob{}, err
+	}
+	return job, nil
+}
+
+func (r *postgresRepository) FinishEnrichmentJob(ctx context.Context, job entity.EnrichmentJob, person entity.Person) error {
+	log.Debug().Int64("job", job.ID).Int("id", person.ID).Str("status", person.EnrichmentStatus).Msg("Calling FinishEnrichmentJob repository")
+
+	tx, err := r.db.BeginTxx(ctx, nil)
+	if err != nil {
+		log.Err(err).Msg("Failed to begin transaction")
+		return err
+	}
+	defer rollback(tx)
+
+	if err := saveEnrichment(ctx, tx, person); err != nil {
+		return r.releaseStaleJob(ctx, job, err)
+	}
+	if _, err := tx.ExecContext(ctx, "DELETE FROM enrichment_jobs WHERE id = $1", job.ID); err != nil {
+		log.Err(err).Int64("job", job.ID).Msg("Failed to delete enrichment job")
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// DeleteEnrichmentJob drops a job whose person no longer exists.
+func (r *postgresRepository) DeleteEnrichmentJob(ctx context.Context, job entity.EnrichmentJob) error {
+	log.Debug().Int64("job", job.ID).Int("person_id", job.PersonID).Msg("Calling DeleteEnrichmentJob repository")
+
+	if _, err := r.db.ExecContext(ctx, "DELETE FROM enrichment_jobs WHERE id = $1", job.ID); err != nil {
+		log.Err(err).Int64("job", job.ID).Msg("Failed to delete enrichment job")
+		return err
+	}
+	return nil
+}
+
+func (r *postgresRepository) RescheduleEnrichmentJob(ctx context.Context, job entity.EnrichmentJob, person entity.Person, delay time.Duration, reason error) error {
+	log.Debug().Int64("job", job.ID).Int("id", person.ID).Dur("delay", delay).Msg("Calling RescheduleEnrichmentJob repository")
+
+	tx, err := r.db.BeginTxx(ctx, nil)
+	if err != nil {
+		log.Err(err).Msg("Failed to begin transaction")
+		return err
+	}
+	defer rollback(tx)
+
+	if err := saveEnrichment(ctx, tx, person); err != nil {
+		return r.releaseStaleJob(ctx, job, err)
+	}
+
+	var lastError *string
+	if reason != nil {
+		message := reason.Error()
+		lastError = &message
+	}
+	_, err = tx.ExecContext(ctx, `
+		UPDATE enrichment_jobs
+		SET run_at = now() + $1::float8 * interval '1 second', locked_until = NULL, last_error = $2
+		WHERE id = $3
+	`, delay.Seconds(), lastError, job.ID)
+	if err != nil {
+		log.Err(err).Int64("job", job.ID).Msg("Failed to reschedule enrichment job")
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// releaseStaleJob makes job due again right away when its result was dropped
+// because the person changed during enrichment, so that the next attempt
+// starts from the current state. The attempt is not counted. Other errors
+// are returned as is.
+func (r *postgresRepository) releaseStaleJob(ctx context.Context, job entity.EnrichmentJob, err error) error {
+	if !errors.Is(err, entity.ErrConflict) {
+		return err
+	}
+
+	_, releaseErr := r.db.ExecContext(ctx, `
+		UPDATE enrichment_jobs
+		SET run_at = now(), locked_until = NULL, attempts = GREATEST(attempts - 1, 0)
+		WHERE id = $1
+	`, job.ID)
+	if releaseErr != nil {
+		log.Err(releaseErr).Int64("job", job.ID).Msg("Failed to release stale enrichment job")
+		return releaseErr
+	}
+	return err
+}
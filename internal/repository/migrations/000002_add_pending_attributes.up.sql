@@ -0,0 +1,2 @@
+-- +migrate Up
+ALTER TABLE people ADD COLUMN IF NOT EXISTS pending_attributes TEXT[];
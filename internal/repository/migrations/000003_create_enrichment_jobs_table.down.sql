@@ -0,0 +1,4 @@
+-- +migrate Down
+DROP TABLE IF EXISTS enrichment_jobs;
+
+ALTER TABLE people DROP COLUMN IF EXISTS enrichment_status;
@@ -0,0 +1,2 @@
+-- +migrate Down
+DROP TABLE IF EXISTS name_enrichment_cache;
@@ -0,0 +1,8 @@
+-- +migrate Up
+CREATE TABLE IF NOT EXISTS name_enrichment_cache (
+    provider VARCHAR(50) NOT NULL,
+    name VARCHAR(255) NOT NULL,
+    payload JSONB NOT NULL,
+    expires_at TIMESTAMPTZ NOT NULL,
+    PRIMARY KEY (provider, name)
+);
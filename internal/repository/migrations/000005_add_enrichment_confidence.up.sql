@@ -0,0 +1,12 @@
+-- +migrate Up
+ALTER TABLE people
+    ADD COLUMN IF NOT EXISTS age_count INT NOT NULL DEFAULT 0,
+    ADD COLUMN IF NOT EXISTS gender_probability REAL NOT NULL DEFAULT 0,
+    ADD COLUMN IF NOT EXISTS nationality_probability REAL NOT NULL DEFAULT 0;
+
+CREATE TABLE IF NOT EXISTS person_nationalities (
+    person_id INT NOT NULL REFERENCES people (id) ON DELETE CASCADE,
+    country_id VARCHAR(255) NOT NULL,
+    probability REAL NOT NULL,
+    PRIMARY KEY (person_id, country_id)
+);
@@ -0,0 +1,2 @@
+-- +migrate Up
+ALTER TABLE people ADD COLUMN IF NOT EXISTS country_id VARCHAR(2) NOT NULL DEFAULT '';
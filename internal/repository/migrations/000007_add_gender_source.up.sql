@@ -0,0 +1,2 @@
+-- +migrate Up
+ALTER TABLE people ADD COLUMN IF NOT EXISTS gender_source VARCHAR(50) NOT NULL DEFAULT '';
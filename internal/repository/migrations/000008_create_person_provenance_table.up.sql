@@ -0,0 +1,12 @@
+-- +migrate Up
+CREATE TABLE IF NOT EXISTS person_provenance (
+    id BIGSERIAL PRIMARY KEY,
+    person_id INT NOT NULL REFERENCES people (id) ON DELETE CASCADE,
+    field VARCHAR(50) NOT NULL,
+    value TEXT NOT NULL,
+    source VARCHAR(50) NOT NULL,
+    response_hash VARCHAR(64) NOT NULL DEFAULT '',
+    recorded_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+CREATE INDEX IF NOT EXISTS person_provenance_person_id_idx ON person_provenance (person_id, recorded_at);
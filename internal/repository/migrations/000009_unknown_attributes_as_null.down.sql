@@ -0,0 +1,4 @@
+-- +migrate Down
+UPDATE people SET age = 0 WHERE age IS NULL;
+UPDATE people SET gender = '' WHERE gender IS NULL;
+UPDATE people SET nationality = '' WHERE nationality IS NULL;
@@ -0,0 +1,2 @@
+-- +migrate Down
+ALTER TABLE people DROP COLUMN IF EXISTS locked_attributes;
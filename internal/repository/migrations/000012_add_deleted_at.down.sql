@@ -0,0 +1,4 @@
+-- +migrate Down
+DROP INDEX IF EXISTS people_deleted_at_idx;
+
+ALTER TABLE people DROP COLUMN IF EXISTS deleted_at;
@@ -0,0 +1,4 @@
+-- +migrate Up
+ALTER TABLE people ADD COLUMN IF NOT EXISTS deleted_at TIMESTAMPTZ;
+
+CREATE INDEX IF NOT EXISTS people_deleted_at_idx ON people (deleted_at) WHERE deleted_at IS NOT NULL;
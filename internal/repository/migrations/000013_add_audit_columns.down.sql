@@ -0,0 +1,9 @@
+-- +migrate Down
+DROP INDEX IF EXISTS people_updated_at_idx;
+DROP INDEX IF EXISTS people_created_at_idx;
+
+ALTER TABLE people
+    DROP COLUMN IF EXISTS updated_by,
+    DROP COLUMN IF EXISTS updated_at,
+    DROP COLUMN IF EXISTS created_by,
+    DROP COLUMN IF EXISTS created_at;
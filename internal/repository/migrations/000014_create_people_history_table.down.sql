@@ -0,0 +1,8 @@
+-- +migrate Down
+DROP TRIGGER IF EXISTS people_history_delete ON people;
+DROP TRIGGER IF EXISTS people_history_update ON people;
+DROP TRIGGER IF EXISTS people_history_insert ON people;
+
+DROP FUNCTION IF EXISTS record_people_history();
+
+DROP TABLE IF EXISTS people_history;
@@ -0,0 +1,54 @@
+-- +migrate Up
+CREATE TABLE IF NOT EXISTS people_history (
+    id BIGSERIAL PRIMARY KEY,
+    person_id INT NOT NULL,
+    operation VARCHAR(20) NOT NULL,
+    version INT NOT NULL,
+    data JSONB NOT NULL,
+    changed_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    changed_by VARCHAR(255) NOT NULL DEFAULT ''
+);
+
+CREATE INDEX IF NOT EXISTS people_history_person_id_idx ON people_history (person_id, changed_at);
+
+CREATE OR REPLACE FUNCTION record_people_history() RETURNS TRIGGER AS $$
+BEGIN
+    IF TG_OP = 'DELETE' THEN
+        DELETE FROM people_history WHERE person_id = OLD.id;
+        RETURN OLD;
+    END IF;
+
+    INSERT INTO people_history (person_id, operation, version, data, changed_by)
+    VALUES (
+        NEW.id,
+        CASE
+            WHEN TG_OP = 'INSERT' THEN 'create'
+            WHEN NEW.deleted_at IS NOT NULL AND OLD.deleted_at IS NULL THEN 'delete'
+            WHEN NEW.deleted_at IS NULL AND OLD.deleted_at IS NOT NULL THEN 'restore'
+            ELSE 'update'
+        END,
+        NEW.version,
+        to_jsonb(NEW),
+        NEW.updated_by
+    );
+    RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
+
+DROP TRIGGER IF EXISTS people_history_insert ON people;
+CREATE TRIGGER people_history_insert AFTER INSERT ON people
+    FOR EACH ROW EXECUTE FUNCTION record_people_history();
+
+DROP TRIGGER IF EXISTS people_history_update ON people;
+CREATE TRIGGER people_history_update AFTER UPDATE ON people
+    FOR EACH ROW WHEN (OLD.* IS DISTINCT FROM NEW.*) EXECUTE FUNCTION record_people_history();
+
+DROP TRIGGER IF EXISTS people_history_delete ON people;
+CREATE TRIGGER people_history_delete AFTER DELETE ON people
+    FOR EACH ROW EXECUTE FUNCTION record_people_history();
+
+-- Earlier states of existing people are unknown, so their history starts
+-- with a baseline snapshot taken now rather than a create at created_at.
+INSERT INTO people_history (person_id, operation, version, data, changed_at, changed_by)
+SELECT id, 'baseline', version, to_jsonb(people), now(), '' FROM people
+WHERE NOT EXISTS (SELECT 1 FROM people_history WHERE people_history.person_id = people.id);
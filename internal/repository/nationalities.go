@@ -0,0 +1,71 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"github.com/OksidGen/enrich_server/internal/entity"
+	"github.com/jmoiron/sqlx"
+	"github.com/rs/zerolog/log"
+	"strings"
+)
+
+func loadNationalities(ctx context.Context, db sqlx.QueryerContext, people []entity.Person) error {
+	if len(people) == 0 {
+		return nil
+	}
+
+	ids := make([]int, 0, len(people))
+	byID := make(map[int]*entity.Person, len(people))
+	for i := range people {
+		ids = append(ids, people[i].ID)
+		byID[people[i].ID] = &people[i]
+	}
+
+	var rows []struct {
+		PersonID int `db:"person_id"`
+		entity.NationalityCandidate
+	}
+	err := sqlx.SelectContext(ctx, db, &rows, `
+		SELECT person_id, country_id, probability FROM person_nationalities
+		WHERE person_id = ANY($1)
+		ORDER BY person_id, probability DESC
+	`, ids)
+	if err != nil {
+		log.Err(err).Msg("Failed to load nationalities")
+		return err
+	}
+
+	for _, row := range rows {
+		person := byID[row.PersonID]
+		person.Nationalities = append(person.Nationalities, row.NationalityCandidate)
+	}
+	return nil
+}
+
+func saveNationalities(ctx context.Context, tx sqlx.ExecerContext, personID int, candidates []entity.NationalityCandidate) error {
+	if _, err := tx.ExecContext(ctx, "DELETE FROM person_nationalities WHERE person_id = $1", personID); err != nil {
+		log.Err(err).Int("id", personID).Msg("Failed to clear nationalities")
+		return err
+	}
+	if len(candidates) == 0 {
+		return nil
+	}
+
+	var values []string
+	args := []interface{}{personID}
+	for i, candidate := range candidates {
+		values = append(values, fmt.Sprintf("($1, $%d, $%d)", 2*i+2, 2*i+3))
+		args = append(args, candidate.CountryID, candidate.Probability)
+	}
+
+	_, err := tx.ExecContext(ctx, `
+		INSERT INTO person_nationalities (person_id, country_id, probability)
+		VALUES `+strings.Join(values, ", ")+`
+		ON CONFLICT (person_id, country_id) DO UPDATE SET probability = EXCLUDED.probability
+	`, args...)
+	if err != nil {
+		log.Err(err).Int("id", personID).Msg("Failed to save nationalities")
+		return err
+	}
+	return nil
+}
@@ -2,11 +2,14 @@ package repository
 
 import (
 	"context"
+	"database/sql"
+	"errors"
 	"fmt"
 	"github.com/OksidGen/enrich_server/internal/entity"
 	"github.com/jmoiron/sqlx"
 	"github.com/rs/zerolog/log"
 	"strings"
+	"time"
 )
 
 type Repository interface {
@@ -14,10 +17,26 @@ type Repository interface {
 	GetPeopleWithFilters(ctx context.Context, filters map[string]interface{}, pagination map[string]int) ([]entity.Person, error)
 	GetPersonByID(ctx context.Context, id int) (entity.Person, error)
 	CreatePerson(ctx context.Context, person entity.Person) (int, error)
-	UpdatePerson(ctx context.Context, id int, updates map[string]interface{}) error
-	DeletePerson(ctx context.Context, id int) error
+	CreatePeople(ctx context.Context, people []entity.Person) ([]entity.BatchResult, error)
+	UpdatePerson(ctx context.Context, id int, version int, updates map[string]interface{}) error
+	UpdateAndEnrichPerson(ctx context.Context, id int, updates map[string]interface{}, person entity.Person) error
+	DeletePerson(ctx context.Context, id int, version int) error
+	RestorePerson(ctx context.Context, id int) error
+	PurgePeople(ctx context.Context, deletedBefore time.Time) (int, error)
+	GetProvenance(ctx context.Context, id int) ([]entity.Provenance, error)
+	GetHistory(ctx context.Context, id int) ([]entity.PersonRevision, error)
+	GetPersonAsOf(ctx context.Context, id int, at time.Time) (entity.Person, error)
+	RequestEnrichment(ctx context.Context, people []entity.Person) error
+	ClaimEnrichmentJob(ctx context.Context, lease time.Duration) (entity.EnrichmentJob, error)
+	FinishEnrichmentJob(ctx context.Context, job entity.EnrichmentJob, person entity.Person) error
+	RescheduleEnrichmentJob(ctx context.Context, job entity.EnrichmentJob, person entity.Person, delay time.Duration, reason error) error
+	DeleteEnrichmentJob(ctx context.Context, job entity.EnrichmentJob) error
 }
 
+const personColumns = "id, name, surname, patronymic, age, age_count, gender, gender_probability, gender_source, " +
+	"nationality, nationality_probability, country_id, enrichment_status, pending_attributes, locked_attributes, version, deleted_at, " +
+	"created_at, created_by, updated_at, updated_by"
+
 type postgresRepository struct {
 	db *sqlx.DB
 }
@@ -30,10 +49,13 @@ func (r *postgresRepository) GetAllPeople(ctx context.Context) ([]entity.Person,
 	log.Debug().Msg("Calling GetAllPeople repository")
 
 	var people []entity.Person
-	err := r.db.SelectContext(ctx, &people, "SELECT id, name, surname, patronymic, age, gender, nationality FROM people")
+	err := r.db.SelectContext(ctx, &people, "SELECT "+personColumns+" FROM people WHERE deleted_at IS NULL")
 	if err != nil {
 		log.Err(err).Msg("Failed to get people")
-		return nil, err
+		return nil, dbError(err)
+	}
+	if err := loadNationalities(ctx, r.db, people); err != nil {
+		return nil, dbError(err)
 	}
 	return people, nil
 }
@@ -41,13 +63,27 @@ func (r *postgresRepository) GetAllPeople(ctx context.Context) ([]entity.Person,
 func (r *postgresRepository) GetPeopleWithFilters(ctx context.Context, filters map[string]interface{}, pagination map[string]int) ([]entity.Person, error) {
 	log.Debug().Interface("filters", filters).Msg("Calling GetPeopleWithFilters repository")
 
-	query := "SELECT * FROM people"
+	query := "SELECT " + personColumns + " FROM people WHERE "
 	var args []interface{}
 	id := 1
 
+	if includeDeleted, _ := filters["include_deleted"].(bool); !includeDeleted {
+		query += "deleted_at IS NULL AND "
+	}
+
 	if len(filters) != 0 {
-		query += " WHERE "
 		for key, value := range filters {
+			if key == "include_deleted" {
+				continue
+			}
+			if value == nil {
+				switch key {
+				case "age", "gender", "nationality":
+					query += fmt.Sprintf("%s IS NULL AND ", key)
+				}
+				continue
+			}
+
 			switch key {
 			case "name", "surname", "patronymic", "gender", "nationality":
 				query += fmt.Sprintf("%s ILIKE $%d AND ", key, id)
@@ -62,11 +98,32 @@ func (r *postgresRepository) GetPeopleWithFilters(ctx context.Context, filters m
 					query += fmt.Sprintf("age <= $%d AND ", id)
 				}
 				args = append(args, value)
+			case "minAgeCount":
+				query += fmt.Sprintf("age_count >= $%d AND ", id)
+				args = append(args, value)
+			case "minGenderProbability":
+				query += fmt.Sprintf("gender_probability >= $%d AND ", id)
+				args = append(args, value)
+			case "minNationalityProbability":
+				query += fmt.Sprintf("nationality_probability >= $%d AND ", id)
+				args = append(args, value)
+			case "createdAfter":
+				query += fmt.Sprintf("created_at > $%d AND ", id)
+				args = append(args, value)
+			case "createdBefore":
+				query += fmt.Sprintf("created_at < $%d AND ", id)
+				args = append(args, value)
+			case "updatedAfter":
+				query += fmt.Sprintf("updated_at > $%d AND ", id)
+				args = append(args, value)
+			case "updatedBefore":
+				query += fmt.Sprintf("updated_at < $%d AND ", id)
+				args = append(args, value)
 			}
 			id++
 		}
-		query = strings.TrimSuffix(query, " AND ")
 	}
+	query = strings.TrimSuffix(strings.TrimSuffix(query, " AND "), " WHERE ")
 
 	if len(pagination) != 0 {
 		page := pagination["page"]
@@ -78,7 +135,11 @@ func (r *postgresRepository) GetPeopleWithFilters(ctx context.Context, filters m
 	var people []entity.Person
 	err := r.db.SelectContext(ctx, &people, query, args...)
 	if err != nil {
-		return nil, err
+		log.Err(err).Msg("Failed to get people with filters")
+		return nil, dbError(err)
+	}
+	if err := loadNationalities(ctx, r.db, people); err != nil {
+		return nil, dbError(err)
 	}
 
 	return people, nil
@@ -88,62 +149,318 @@ func (r *postgresRepository) GetPersonByID(ctx context.Context, id int) (entity.
 	log.Debug().Int("id", id).Msgf("Calling GetPersonByID repository")
 
 	var person entity.Person
-	err := r.db.GetContext(ctx, &person, "SELECT * FROM people WHERE id = $1", id)
+	err := r.db.GetContext(ctx, &person, "SELECT "+personColumns+" FROM people WHERE id = $1 AND deleted_at IS NULL", id)
 	if err != nil {
 		log.Err(err).Int("id", id).Msg("Failed to get person by ID")
-		return entity.Person{}, err
+		if errors.Is(err, sql.ErrNoRows) {
+			return entity.Person{}, fmt.Errorf("person %d: %w", id, entity.ErrNotFound)
+		}
+		return entity.Person{}, dbError(err)
+	}
+
+	people := []entity.Person{person}
+	if err := loadNationalities(ctx, r.db, people); err != nil {
+		return entity.Person{}, dbError(err)
 	}
-	return person, nil
+	return people[0], nil
 }
 
 func (r *postgresRepository) CreatePerson(ctx context.Context, person entity.Person) (int, error) {
 	log.Debug().Interface("person", person).Msg("Calling CreatePerson repository")
 
+	tx, err := r.db.BeginTxx(ctx, nil)
+	if err != nil {
+		log.Err(err).Msg("Failed to begin transaction")
+		return 0, dbError(err)
+	}
+	defer rollback(tx)
+
 	var id int
-	err := r.db.QueryRowContext(ctx, `
-		INSERT INTO people (name, surname, patronymic, age, gender, nationality)
-		VALUES ($1, $2, $3, $4, $5, $6)
+	err = tx.QueryRowContext(ctx, `
+		INSERT INTO people (name, surname, patronymic, age, age_count, gender, gender_probability, gender_source,
+			nationality, nationality_probability, country_id, enrichment_status, pending_attributes, locked_attributes,
+			created_by, updated_by)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $15)
 		RETURNING id
-	`, person.Name, person.Surname, person.Patronymic, person.Age, person.Gender, person.Nationality).Scan(&id)
+	`, person.Name, person.Surname, person.Patronymic, person.Age, person.AgeCount, person.Gender, person.GenderProbability,
+		person.GenderSource, person.Nationality, person.NationalityProbability, person.CountryID, person.EnrichmentStatus,
+		person.PendingAttributes, person.LockedAttributes, entity.ActorFrom(ctx)).Scan(&id)
 	if err != nil {
 		log.Err(err).Interface("person", person).Msg("Failed to create person")
-		return 0, err
+		return 0, dbError(err)
+	}
+
+	if err := saveNationalities(ctx, tx, id, person.Nationalities); err != nil {
+		return 0, dbError(err)
+	}
+	if err := saveProvenance(ctx, tx, id, person.Provenance); err != nil {
+		return 0, dbError(err)
+	}
+
+	if person.EnrichmentStatus == entity.EnrichmentPending {
+		if err := enqueueEnrichment(ctx, tx, id); err != nil {
+			return 0, dbError(err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		log.Err(err).Int("id", id).Msg("Failed to commit transaction")
+		return 0, dbError(err)
 	}
 	return id, nil
 }
 
-func (r *postgresRepository) UpdatePerson(ctx context.Context, id int, updates map[string]interface{}) error {
-	log.Debug().Int("id", id).Interface("updates", updates).Msg("Calling UpdatePerson repository")
+// UpdatePerson applies updates to the person if it is still at version.
+func (r *postgresRepository) UpdatePerson(ctx context.Context, id int, version int, updates map[string]interface{}) error {
+	log.Debug().Int("id", id).Int("version", version).Interface("updates", updates).Msg("Calling UpdatePerson repository")
+
+	tx, err := r.db.BeginTxx(ctx, nil)
+	if err != nil {
+		log.Err(err).Msg("Failed to begin transaction")
+		return dbError(err)
+	}
+	defer rollback(tx)
+
+	if err := checkVersion(ctx, tx, id, version); err != nil {
+		return dbError(err)
+	}
+	if err := updatePerson(ctx, tx, id, updates); err != nil {
+		return dbError(err)
+	}
+
+	return dbError(tx.Commit())
+}
+
+// UpdateAndEnrichPerson saves the enrichment of person with updates applied
+// on top of it as a single change. person must still be at person.Version.
+func (r *postgresRepository) UpdateAndEnrichPerson(ctx context.Context, id int, updates map[string]interface{}, person entity.Person) error {
+	log.Debug().Int("id", id).Interface("updates", updates).Msg("Calling UpdateAndEnrichPerson repository")
+
+	tx, err := r.db.BeginTxx(ctx, nil)
+	if err != nil {
+		log.Err(err).Msg("Failed to begin transaction")
+		return dbError(err)
+	}
+	defer rollback(tx)
+
+	person.ID = id
+	if err := checkVersion(ctx, tx, id, person.Version); err != nil {
+		return dbError(err)
+	}
+
+	columns := enrichmentColumns(person)
+	for field, value := range updates {
+		columns[field] = value
+	}
+	if err := writePerson(ctx, tx, id, columns); err != nil {
+		return dbError(err)
+	}
+	if err := saveNationalities(ctx, tx, id, person.Nationalities); err != nil {
+		return dbError(err)
+	}
+	provenance := append(person.Provenance, manualProvenance(updates)...)
+	if err := saveProvenance(ctx, tx, id, provenance); err != nil {
+		return dbError(err)
+	}
+	if person.EnrichmentStatus == entity.EnrichmentPending {
+		if err := enqueueEnrichment(ctx, tx, id); err != nil {
+			return dbError(err)
+		}
+	}
+
+	return dbError(tx.Commit())
+}
+
+// enrichmentColumns returns the columns filled by enrichment.
+func enrichmentColumns(person entity.Person) map[string]interface{} {
+	return map[string]interface{}{
+		"age":                     person.Age,
+		"age_count":               person.AgeCount,
+		"gender":                  person.Gender,
+		"gender_probability":      person.GenderProbability,
+		"gender_source":           person.GenderSource,
+		"nationality":             person.Nationality,
+		"nationality_probability": person.NationalityProbability,
+		"enrichment_status":       person.EnrichmentStatus,
+		"pending_attributes":      person.PendingAttributes,
+	}
+}
 
+// checkVersion locks the person row and fails with entity.ErrConflict if it
+// was modified since version was read.
+func checkVersion(ctx context.Context, tx *sqlx.Tx, id int, version int) error {
+	var current int
+	err := tx.GetContext(ctx, &current, "SELECT version FROM people WHERE id = $1 AND deleted_at IS NULL FOR UPDATE", id)
+	if errors.Is(err, sql.ErrNoRows) {
+		return fmt.Errorf("person %d: %w", id, entity.ErrNotFound)
+	}
+	if err != nil {
+		log.Err(err).Int("id", id).Msg("Failed to check person version")
+		return err
+	}
+	if current != version {
+		return fmt.Errorf("person %d was modified concurrently: %w", id, entity.ErrConflict)
+	}
+	return nil
+}
+
+// updatePerson applies operator updates, bumps the version and records the
+// updates as manual provenance.
+func updatePerson(ctx context.Context, tx *sqlx.Tx, id int, updates map[string]interface{}) error {
+	if len(updates) == 0 {
+		return nil
+	}
+	if err := writePerson(ctx, tx, id, updates); err != nil {
+		return err
+	}
+	return saveProvenance(ctx, tx, id, manualProvenance(updates))
+}
+
+// writePerson sets columns of the person in one statement that bumps the
+// version once.
+func writePerson(ctx context.Context, tx *sqlx.Tx, id int, columns map[string]interface{}) error {
 	updateQuery := "UPDATE people SET "
 	var args []interface{}
 	i := 1
 
-	for field, value := range updates {
+	for field, value := range columns {
 		updateQuery += fmt.Sprintf("%s = $%d, ", field, i)
 
 		args = append(args, value)
 		i++
 	}
 
-	updateQuery = updateQuery[:len(updateQuery)-2] + fmt.Sprintf(" WHERE id = $%d", i)
+	updateQuery += fmt.Sprintf("version = version + 1, updated_at = now(), updated_by = $%d WHERE id = $%d", i, i+1)
 
-	args = append(args, id)
+	args = append(args, entity.ActorFrom(ctx), id)
 
-	_, err := r.db.ExecContext(ctx, updateQuery, args...)
+	result, err := tx.ExecContext(ctx, updateQuery, args...)
 	if err != nil {
-		log.Err(err).Int("id", id).Interface("updates", updates).Msg("Failed to update person")
+		log.Err(err).Int("id", id).Interface("columns", columns).Msg("Failed to update person")
 		return err
 	}
-
-	return nil
+	return expectAffected(result, id)
 }
 
-func (r *postgresRepository) DeletePerson(ctx context.Context, id int) error {
-	_, err := r.db.ExecContext(ctx, "DELETE FROM people WHERE id = $1", id)
+// DeletePerson soft-deletes the person and drops its pending enrichment. A
+// non-zero version must match the current one or
+// entity.ErrPreconditionFailed is returned.
+func (r *postgresRepository) DeletePerson(ctx context.Context, id int, version int) error {
+	log.Debug().Int("id", id).Int("version", version).Msg("Calling DeletePerson repository")
+
+	tx, err := r.db.BeginTxx(ctx, nil)
+	if err != nil {
+		log.Err(err).Msg("Failed to begin transaction")
+		return dbError(err)
+	}
+	defer rollback(tx)
+
+	result, err := tx.ExecContext(ctx, `
+		UPDATE people SET deleted_at = now(), version = version + 1, updated_at = now(), updated_by = $3
+		WHERE id = $1 AND deleted_at IS NULL AND ($2 = 0 OR version = $2)
+	`, id, version, entity.ActorFrom(ctx))
 	if err != nil {
 		log.Err(err).Int("id", id).Msg("Failed to delete person")
+		return dbError(err)
+	}
+	err = expectAffected(result, id)
+	if errors.Is(err, entity.ErrNotFound) && version != 0 {
+		var exists bool
+		if err := tx.GetContext(ctx, &exists, "SELECT EXISTS (SELECT 1 FROM people WHERE id = $1 AND deleted_at IS NULL)", id); err != nil {
+			log.Err(err).Int("id", id).Msg("Failed to check person")
+			return dbError(err)
+		}
+		if exists {
+			return fmt.Errorf("person %d is not at version %d: %w", id, version, entity.ErrPreconditionFailed)
+		}
+	}
+	if err != nil {
 		return err
 	}
+
+	if _, err := tx.ExecContext(ctx, "DELETE FROM enrichment_jobs WHERE person_id = $1", id); err != nil {
+		log.Err(err).Int("id", id).Msg("Failed to drop enrichment job")
+		return dbError(err)
+	}
+
+	return dbError(tx.Commit())
+}
+
+// RestorePerson undoes a soft delete. Restoring a person that is not deleted
+// is a no-op.
+func (r *postgresRepository) RestorePerson(ctx context.Context, id int) error {
+	log.Debug().Int("id", id).Msg("Calling RestorePerson repository")
+
+	tx, err := r.db.BeginTxx(ctx, nil)
+	if err != nil {
+		log.Err(err).Msg("Failed to begin transaction")
+		return dbError(err)
+	}
+	defer rollback(tx)
+
+	var status string
+	err = tx.GetContext(ctx, &status, `
+		UPDATE people SET deleted_at = NULL, version = version + 1, updated_at = now(), updated_by = $2
+		WHERE id = $1 AND deleted_at IS NOT NULL
+		RETURNING enrichment_status
+	`, id, entity.ActorFrom(ctx))
+	if errors.Is(err, sql.ErrNoRows) {
+		var exists bool
+		if err := tx.GetContext(ctx, &exists, "SELECT EXISTS (SELECT 1 FROM people WHERE id = $1)", id); err != nil {
+			log.Err(err).Int("id", id).Msg("Failed to check person")
+			return dbError(err)
+		}
+		if !exists {
+			return fmt.Errorf("person %d: %w", id, entity.ErrNotFound)
+		}
+		return nil
+	}
+	if err != nil {
+		log.Err(err).Int("id", id).Msg("Failed to restore person")
+		return dbError(err)
+	}
+
+	if status == entity.EnrichmentPending {
+		if err := enqueueEnrichment(ctx, tx, id); err != nil {
+			return dbError(err)
+		}
+	}
+
+	return dbError(tx.Commit())
+}
+
+// PurgePeople hard-deletes people soft-deleted before deletedBefore.
+func (r *postgresRepository) PurgePeople(ctx context.Context, deletedBefore time.Time) (int, error) {
+	log.Debug().Time("deleted_before", deletedBefore).Msg("Calling PurgePeople repository")
+
+	result, err := r.db.ExecContext(ctx, "DELETE FROM people WHERE deleted_at < $1", deletedBefore)
+	if err != nil {
+		log.Err(err).Msg("Failed to purge people")
+		return 0, dbError(err)
+	}
+	purged, err := result.RowsAffected()
+	if err != nil {
+		log.Err(err).Msg("Failed to get affected rows")
+		return 0, err
+	}
+	return int(purged), nil
+}
+
+// expectAffected fails with entity.ErrNotFound if result touched no rows.
+func expectAffected(result sql.Result, id int) error {
+	affected, err := result.RowsAffected()
+	if err != nil {
+		log.Err(err).Int("id", id).Msg("Failed to get affected rows")
+		return err
+	}
+	if affected == 0 {
+		return fmt.Errorf("person %d: %w", id, entity.ErrNotFound)
+	}
 	return nil
 }
+
+func rollback(tx *sqlx.Tx) {
+	if err := tx.Rollback(); err != nil && !errors.Is(err, sql.ErrTxDone) {
+		log.Err(err).Msg("Failed to rollback transaction")
+	}
+}
@@ -0,0 +1,79 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"github.com/OksidGen/enrich_server/internal/entity"
+	"github.com/jmoiron/sqlx"
+	"github.com/rs/zerolog/log"
+	"strings"
+)
+
+func saveProvenance(ctx context.Context, tx sqlx.ExecerContext, personID int, records []entity.Provenance) error {
+	if len(records) == 0 {
+		return nil
+	}
+
+	var values []string
+	args := []interface{}{personID}
+	for i, record := range records {
+		values = append(values, fmt.Sprintf("($1, $%d, $%d, $%d, $%d)", 4*i+2, 4*i+3, 4*i+4, 4*i+5))
+		args = append(args, record.Field, record.Value, record.Source, record.ResponseHash)
+	}
+
+	_, err := tx.ExecContext(ctx, `
+		INSERT INTO person_provenance (person_id, field, value, source, response_hash)
+		VALUES `+strings.Join(values, ", "), args...)
+	if err != nil {
+		log.Err(err).Int("id", personID).Msg("Failed to save provenance")
+		return err
+	}
+	return nil
+}
+
+// untrackedFields are bookkeeping columns that carry no provenance of their own.
+var untrackedFields = map[string]bool{
+	"gender_source":     true,
+	"locked_attributes": true,
+}
+
+func manualProvenance(updates map[string]interface{}) []entity.Provenance {
+	records := make([]entity.Provenance, 0, len(updates))
+	for field, value := range updates {
+		if untrackedFields[field] {
+			continue
+		}
+		record := entity.Provenance{Field: field, Source: entity.SourceManual}
+		if value != nil {
+			record.Value = fmt.Sprint(value)
+		}
+		records = append(records, record)
+	}
+	return records
+}
+
+func (r *postgresRepository) GetProvenance(ctx context.Context, id int) ([]entity.Provenance, error) {
+	log.Debug().Int("id", id).Msg("Calling GetProvenance repository")
+
+	records := []entity.Provenance{}
+	err := r.db.SelectContext(ctx, &records, `
+		SELECT id, person_id, field, value, source, response_hash, recorded_at FROM person_provenance
+		WHERE person_id = $1
+		ORDER BY recorded_at DESC, id DESC
+	`, id)
+	if err != nil {
+		log.Err(err).Int("id", id).Msg("Failed to get provenance")
+		return nil, dbError(err)
+	}
+	if len(records) == 0 {
+		var exists bool
+		if err := r.db.GetContext(ctx, &exists, "SELECT EXISTS (SELECT 1 FROM people WHERE id = $1)", id); err != nil {
+			log.Err(err).Int("id", id).Msg("Failed to check person")
+			return nil, dbError(err)
+		}
+		if !exists {
+			return nil, fmt.Errorf("person %d: %w", id, entity.ErrNotFound)
+		}
+	}
+	return records, nil
+}
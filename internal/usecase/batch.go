@@ -0,0 +1,97 @@
+package usecase
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"github.com/OksidGen/enrich_server/internal/entity"
+	"github.com/rs/zerolog/log"
+	"sync"
+)
+
+// BulkOptions limits the size of a bulk request and how many of its people
+// are enriched at once.
+type BulkOptions struct {
+	MaxItems    int
+	Concurrency int
+}
+
+// CreatePeople validates and enriches every item and inserts the valid ones
+// together. Invalid items and items rejected by the database are reported in
+// their result without failing the rest of the batch.
+func (uc *usecase) CreatePeople(ctx context.Context, items []json.RawMessage) ([]entity.BatchResult, error) {
+	log.Debug().Int("count", len(items)).Msg("Calling CreatePeople usecase")
+
+	var verr entity.ValidationError
+	if len(items) == 0 {
+		verr.Add("items", entity.ViolationEmpty, "must not be empty")
+	}
+	if uc.bulk.MaxItems > 0 && len(items) > uc.bulk.MaxItems {
+		verr.Add("items", entity.ViolationRange, "must contain at most %d items", uc.bulk.MaxItems)
+	}
+	if err := verr.Err(); err != nil {
+		return nil, err
+	}
+
+	results := make([]entity.BatchResult, len(items))
+	people := make([]entity.Person, 0, len(items))
+	indices := make([]int, 0, len(items))
+	for i, item := range items {
+		var req entity.PersonRequest
+		if err := entity.DecodePersonRequest(bytes.NewReader(item), &req); err != nil {
+			results[i].Err = err
+			continue
+		}
+		person, err := uc.newPerson(req)
+		if err != nil {
+			results[i].Err = err
+			continue
+		}
+		people = append(people, person)
+		indices = append(indices, i)
+	}
+
+	uc.enrichPeople(ctx, people)
+
+	created, err := uc.repo.CreatePeople(ctx, people)
+	if err != nil {
+		return nil, err
+	}
+	for i, result := range created {
+		results[indices[i]] = result
+	}
+	return results, nil
+}
+
+// enrichPeople enriches pending people with at most bulk.Concurrency of them
+// in flight. Attributes that could not be resolved stay pending for the
+// worker.
+func (uc *usecase) enrichPeople(ctx context.Context, people []entity.Person) {
+	concurrency := uc.bulk.Concurrency
+	if concurrency <= 0 {
+		concurrency = 1
+	}
+
+	semaphore := make(chan struct{}, concurrency)
+	var wg sync.WaitGroup
+	for i := range people {
+		if people[i].EnrichmentStatus != entity.EnrichmentPending {
+			continue
+		}
+		wg.Add(1)
+		semaphore <- struct{}{}
+		go func(person *entity.Person) {
+			defer func() {
+				<-semaphore
+				wg.Done()
+			}()
+			if err := uc.enricher.Enrich(ctx, person); err != nil {
+				log.Err(err).Str("name", person.Name).Msg("Failed to enrich person")
+			}
+			if len(person.PendingAttributes) == 0 {
+				person.EnrichmentStatus = entity.EnrichmentDone
+			}
+		}(&people[i])
+	}
+	wg.Wait()
+}
@@ -0,0 +1,172 @@
+package usecase
+
+import (
+	"context"
+	"github.com/OksidGen/enrich_server/internal/enricher"
+	"github.com/OksidGen/enrich_server/internal/entity"
+	"github.com/rs/zerolog/log"
+)
+
+func (uc *usecase) EnrichPerson(ctx context.Context, id int, overwrite bool) error {
+	log.Debug().Int("id", id).Bool("overwrite", overwrite).Msg("Calling EnrichPerson usecase")
+
+	person, err := uc.repo.GetPersonByID(ctx, id)
+	if err != nil {
+		return err
+	}
+
+	people := uc.pendingEnrichment([]entity.Person{person}, overwrite)
+	if len(people) == 0 {
+		return nil
+	}
+	return uc.repo.RequestEnrichment(ctx, people)
+}
+
+func (uc *usecase) EnrichPeople(ctx context.Context, params map[string]interface{}, overwrite bool) (int, error) {
+	log.Debug().Interface("params", params).Bool("overwrite", overwrite).Msg("Calling EnrichPeople usecase")
+
+	people, err := uc.GetPeople(ctx, params)
+	if err != nil {
+		return 0, err
+	}
+
+	people = uc.pendingEnrichment(people, overwrite)
+	if len(people) == 0 {
+		return 0, nil
+	}
+	if err := uc.repo.RequestEnrichment(ctx, people); err != nil {
+		return 0, err
+	}
+	return len(people), nil
+}
+
+// pendingEnrichment returns people that need enrichment with PendingAttributes
+// set. Unless overwrite is set only empty attributes are requested. Locked
+// attributes are never requested.
+func (uc *usecase) pendingEnrichment(people []entity.Person, overwrite bool) []entity.Person {
+	var pending []entity.Person
+	for _, person := range people {
+		if person.DeletedAt != nil {
+			continue
+		}
+		person.PendingAttributes = nil
+		for _, attribute := range uc.enricher.Attributes() {
+			if person.LockedAttributes.Contains(attribute) {
+				continue
+			}
+			if overwrite || enricher.Empty(person, attribute) {
+				person.PendingAttributes = append(person.PendingAttributes, attribute)
+			}
+		}
+
+		if len(person.PendingAttributes) != 0 {
+			pending = append(pending, person)
+		}
+	}
+	return pending
+}
+
+// updateAndEnrichPerson saves updates together with a fresh enrichment of
+// the cleared attributes and, when refresh is set, of every attribute derived
+// from a new name that the caller neither supplied nor locked. Without enrich
+// the attributes are left to the worker.
+func (uc *usecase) updateAndEnrichPerson(ctx context.Context, person entity.Person, updates map[string]interface{}, cleared entity.Attributes, enrich bool) error {
+	_, renamed := updates["name"]
+	if name, ok := updates["name"].(string); ok {
+		person.Name = name
+	}
+	if locked, ok := updates["locked_attributes"].(entity.Attributes); ok {
+		person.LockedAttributes = locked
+	}
+	for _, attribute := range cleared {
+		enricher.Clear(&person, attribute)
+	}
+
+	person.PendingAttributes = nil
+	for _, attribute := range uc.enricher.Attributes() {
+		if person.LockedAttributes.Contains(attribute) {
+			continue
+		}
+		_, supplied := updates[attribute]
+		if cleared.Contains(attribute) || (renamed && enrich && !supplied) {
+			person.PendingAttributes = append(person.PendingAttributes, attribute)
+		}
+	}
+	if len(person.PendingAttributes) == 0 {
+		return uc.repo.UpdatePerson(ctx, person.ID, person.Version, updates)
+	}
+
+	if enrich {
+		if err := uc.enricher.Enrich(ctx, &person); err != nil {
+			log.Warn().Err(err).Int("id", person.ID).Msg("Person was enriched partially")
+		}
+	}
+	person.EnrichmentStatus = entity.EnrichmentDone
+	if len(person.PendingAttributes) != 0 {
+		person.EnrichmentStatus = entity.EnrichmentPending
+	}
+
+	if err := uc.repo.UpdateAndEnrichPerson(ctx, person.ID, updates, person); err != nil {
+		log.Err(err).Msg("Failed to update person")
+		return err
+	}
+	return nil
+}
+
+// manualAttributes returns the enriched attributes given a value in data.
+func (uc *usecase) manualAttributes(data map[string]interface{}) entity.Attributes {
+	var supplied entity.Attributes
+	for _, attribute := range uc.enricher.Attributes() {
+		if value, ok := data[attribute]; ok && value != nil {
+			supplied = append(supplied, attribute)
+		}
+	}
+	return supplied
+}
+
+// lockManualAttributes locks the enriched attributes given a value in updates
+// so that re-enrichment keeps the operator's values. Cleared attributes are
+// unlocked, removed from updates and returned to be enriched again. An
+// explicit change of locked_attributes in updates replaces the locks instead,
+// and cleared attributes locked by it stay empty.
+func (uc *usecase) lockManualAttributes(person entity.Person, updates map[string]interface{}) entity.Attributes {
+	supplied := uc.manualAttributes(updates)
+	if supplied.Contains(enricher.AttributeGender) {
+		updates["gender_source"] = entity.SourceManual
+	}
+
+	locked, explicit := updates["locked_attributes"].(entity.Attributes)
+	if !explicit {
+		locked = append(entity.Attributes{}, person.LockedAttributes...)
+	}
+
+	var cleared entity.Attributes
+	for _, attribute := range uc.enricher.Attributes() {
+		if value, ok := updates[attribute]; !ok || value != nil {
+			continue
+		}
+		if !explicit {
+			locked = locked.Without(attribute)
+		}
+		if locked.Contains(attribute) {
+			if attribute == enricher.AttributeGender {
+				updates["gender_source"] = ""
+			}
+			continue
+		}
+		cleared = append(cleared, attribute)
+		delete(updates, attribute)
+	}
+
+	if !explicit {
+		for _, attribute := range supplied {
+			if !locked.Contains(attribute) {
+				locked = append(locked, attribute)
+			}
+		}
+		if !sameAttributes(locked, person.LockedAttributes) {
+			updates["locked_attributes"] = locked
+		}
+	}
+	return cleared
+}
@@ -1,31 +1,53 @@
 package usecase
 
 import (
+	"bytes"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"github.com/OksidGen/enrich_server/internal/enricher"
 	"github.com/OksidGen/enrich_server/internal/entity"
 	"github.com/OksidGen/enrich_server/internal/repository"
 	"github.com/rs/zerolog/log"
-	"io"
-	"net/http"
+	"reflect"
 	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
 )
 
 type Usecase interface {
 	GetPeople(ctx context.Context, params map[string]interface{}) ([]entity.Person, error)
 	GetPersonByID(ctx context.Context, id int) (entity.Person, error)
-	CreatePerson(ctx context.Context, params map[string]interface{}) (int, error)
-	UpdatePerson(ctx context.Context, id int, updates map[string]interface{}) error
-	DeletePerson(ctx context.Context, id int) error
+	GetPersonAsOf(ctx context.Context, id int, asOf string) (entity.Person, error)
+	GetProvenance(ctx context.Context, id int) ([]entity.Provenance, error)
+	GetHistory(ctx context.Context, id int) ([]entity.PersonRevision, error)
+	CreatePerson(ctx context.Context, req entity.PersonRequest) (int, error)
+	CreatePeople(ctx context.Context, items []json.RawMessage) ([]entity.BatchResult, error)
+	ReplacePerson(ctx context.Context, id int, version int, req entity.PersonRequest, enrich bool) error
+	PatchPerson(ctx context.Context, id int, version int, format string, patch []byte, enrich bool) error
+	DeletePerson(ctx context.Context, id int, version int, idempotent bool) error
+	RestorePerson(ctx context.Context, id int) error
+	PurgePeople(ctx context.Context) (int, error)
+	EnrichPerson(ctx context.Context, id int, overwrite bool) error
+	EnrichPeople(ctx context.Context, params map[string]interface{}, overwrite bool) (int, error)
+	GetEnrichmentStatus(ctx context.Context) []enricher.ProviderStatus
 }
 
+// unknownValue in a filter selects people whose attribute is not known.
+const unknownValue = "null"
+
 type usecase struct {
-	repo repository.Repository
+	repo             repository.Repository
+	enricher         enricher.Enricher
+	defaultCountryID string
+	purgeRetention   time.Duration
+	bulk             BulkOptions
 }
 
-func NewUsecase(repo repository.Repository) Usecase {
-	return &usecase{repo}
+func NewUsecase(repo repository.Repository, enricher enricher.Enricher, defaultCountryID string, purgeRetention time.Duration, bulk BulkOptions) Usecase {
+	return &usecase{repo, enricher, strings.ToUpper(defaultCountryID), purgeRetention, bulk}
 }
 
 func (uc *usecase) GetPeople(ctx context.Context, params map[string]interface{}) ([]entity.Person, error) {
@@ -39,36 +61,34 @@ func (uc *usecase) GetPeople(ctx context.Context, params map[string]interface{})
 
 	filters := make(map[string]interface{})
 	pagination := make(map[string]int)
+	var verr entity.ValidationError
 
-	if pageStr, ok := params["page"]; ok {
-		page, err := strconv.Atoi(pageStr.(string))
+	atoi := func(param string) int {
+		value, err := strconv.Atoi(params[param].(string))
 		if err != nil {
-			log.Error().Err(err).Str("param", "page").Str("value", pageStr.(string)).Msg("Failed to convert page to int")
-			return nil, err
+			log.Error().Err(err).Str("param", param).Interface("value", params[param]).Msg("Failed to convert value of param to int")
+			verr.Add(param, entity.ViolationType, "must be an integer")
 		}
-		if limitStr, ok := params["limit"]; ok {
-			limit, err := strconv.Atoi(limitStr.(string))
-			if err != nil {
-				log.Error().Err(err).Str("param", "limit").Str("value", limitStr.(string)).Msg("Failed to convert limit to int")
-				return nil, err
-			}
-			pagination["page"] = page
-			pagination["limit"] = limit
-		} else {
-			pagination["page"] = page
-			pagination["limit"] = 10
+		return value
+	}
+	positive := func(param string) int {
+		violations := len(verr.Violations)
+		value := atoi(param)
+		if len(verr.Violations) == violations && value < 1 {
+			verr.Add(param, entity.ViolationRange, "must be at least 1")
 		}
+		return value
+	}
 
-	} else {
-		if limitStr, ok := params["limit"]; ok {
-			limit, err := strconv.Atoi(limitStr.(string))
-			if err != nil {
-				log.Error().Err(err).Str("param", "limit").Str("value", limitStr.(string)).Msg("Failed to convert limit to int")
-				return nil, err
-			}
-			pagination["page"] = 1
-			pagination["limit"] = limit
+	if _, ok := params["page"]; ok {
+		pagination["page"] = positive("page")
+		pagination["limit"] = 10
+		if _, ok := params["limit"]; ok {
+			pagination["limit"] = positive("limit")
 		}
+	} else if _, ok := params["limit"]; ok {
+		pagination["page"] = 1
+		pagination["limit"] = positive("limit")
 	}
 
 	delete(params, "page")
@@ -77,20 +97,53 @@ func (uc *usecase) GetPeople(ctx context.Context, params map[string]interface{})
 	for param, value := range params {
 		switch param {
 		case "age", "minAge", "maxAge":
-			numValue, err := strconv.Atoi(value.(string))
-			if err != nil {
-				log.Error().Err(err).Str("param", param).Str("value", value.(string)).Msg("Failed to convert value of param to int")
-				return nil, err
+			if param == "age" && value == unknownValue {
+				filters[param] = nil
+				continue
+			}
+			filters[param] = atoi(param)
+		case "minAgeCount":
+			filters[param] = atoi(param)
+		case "minGenderProbability", "minNationalityProbability":
+			probability, err := strconv.ParseFloat(value.(string), 64)
+			if err != nil || probability < 0 || probability > 1 {
+				log.Error().Str("param", param).Interface("value", value).Msg("Invalid query param")
+				verr.Add(param, entity.ViolationRange, "must be a number between 0 and 1")
+				continue
 			}
-			filters[param] = numValue
-		case "name", "surname", "patronymic", "gender", "nationality":
+			filters[param] = probability
+		case "gender", "nationality":
+			if value == unknownValue {
+				filters[param] = nil
+				continue
+			}
+			filters[param] = value
+		case "name", "surname", "patronymic":
 			filters[param] = value
+		case "createdAfter", "createdBefore", "updatedAfter", "updatedBefore":
+			timestamp, err := parseTimestamp(value.(string))
+			if err != nil {
+				log.Error().Err(err).Str("param", param).Interface("value", value).Msg("Invalid query param")
+				verr.Add(param, entity.ViolationType, "must be an RFC 3339 timestamp or a date")
+				continue
+			}
+			filters[param] = timestamp
+		case "include_deleted":
+			includeDeleted, err := strconv.ParseBool(value.(string))
+			if err != nil {
+				log.Error().Err(err).Str("param", param).Interface("value", value).Msg("Invalid query param")
+				verr.Add(param, entity.ViolationType, "must be a boolean")
+				continue
+			}
+			filters[param] = includeDeleted
 		default:
-			err := fmt.Errorf("invalid query param: %s", param)
-			log.Err(err).Str("param", param).Msg("Invalid query param")
-			return nil, err
+			log.Error().Str("param", param).Msg("Invalid query param")
+			verr.Add(param, entity.ViolationUnknown, "is not a supported query param")
 		}
 	}
+	if err := verr.Err(); err != nil {
+		return nil, err
+	}
 	if _, hasAge := filters["age"]; hasAge {
 		delete(filters, "minAge")
 		delete(filters, "maxAge")
@@ -104,7 +157,7 @@ func (uc *usecase) GetPeople(ctx context.Context, params map[string]interface{})
 		}
 	}
 
-	return uc.repo.GetPeopleWithFilters(ctx, params, pagination)
+	return uc.repo.GetPeopleWithFilters(ctx, filters, pagination)
 }
 
 func (uc *usecase) GetPersonByID(ctx context.Context, id int) (entity.Person, error) {
@@ -112,197 +165,378 @@ func (uc *usecase) GetPersonByID(ctx context.Context, id int) (entity.Person, er
 	return uc.repo.GetPersonByID(ctx, id)
 }
 
-func (uc *usecase) CreatePerson(ctx context.Context, params map[string]interface{}) (int, error) {
-	log.Debug().Interface("params", params).Msg("Calling CreatePerson usecase")
+// GetPersonAsOf returns the person as it was at asOf, an RFC 3339 timestamp
+// or a date.
+func (uc *usecase) GetPersonAsOf(ctx context.Context, id int, asOf string) (entity.Person, error) {
+	log.Debug().Int("id", id).Str("as_of", asOf).Msg("Calling GetPersonAsOf usecase")
 
-	if err := validateFields(params); err != nil {
-		log.Err(err).Msg("Failed to validate fields")
-		return 0, err
-	}
-	var person entity.Person
-	if err := person.MapToPerson(params); err != nil {
-		log.Err(err).Msg("Failed to map person")
-		return 0, err
+	at, err := parseTimestamp(asOf)
+	if err != nil {
+		var verr entity.ValidationError
+		verr.Add("as_of", entity.ViolationType, "must be an RFC 3339 timestamp or a date")
+		return entity.Person{}, &verr
 	}
-	enrichPersonData(&person)
+	return uc.repo.GetPersonAsOf(ctx, id, at)
+}
 
-	return uc.repo.CreatePerson(ctx, person)
+func (uc *usecase) GetHistory(ctx context.Context, id int) ([]entity.PersonRevision, error) {
+	log.Debug().Int("id", id).Msg("Calling GetHistory usecase")
+	return uc.repo.GetHistory(ctx, id)
 }
 
-func (uc *usecase) UpdatePerson(ctx context.Context, id int, updates map[string]interface{}) error {
-	log.Debug().Int("id", id).Interface("updates", updates).Msg("Calling UpdatePerson usecase")
+func (uc *usecase) GetProvenance(ctx context.Context, id int) ([]entity.Provenance, error) {
+	log.Debug().Int("id", id).Msg("Calling GetProvenance usecase")
+	return uc.repo.GetProvenance(ctx, id)
+}
 
-	if err := validateFields(updates); err != nil {
-		log.Err(err).Msg("Failed to validate fields")
-		return err
-	}
+func (uc *usecase) CreatePerson(ctx context.Context, req entity.PersonRequest) (int, error) {
+	log.Debug().Interface("request", req).Msg("Calling CreatePerson usecase")
 
-	err := uc.repo.UpdatePerson(ctx, id, updates)
+	person, err := uc.newPerson(req)
 	if err != nil {
-		log.Err(err).Msg("Failed to update person")
-		return err
+		return 0, err
 	}
-
-	return nil
+	return uc.repo.CreatePerson(ctx, person)
 }
-func (uc *usecase) DeletePerson(ctx context.Context, id int) error {
-	log.Debug().Int("id", id).Msg("Calling DeletePerson usecase")
-	return uc.repo.DeletePerson(ctx, id)
+
+// newPerson validates req and builds the person to insert with every
+// attribute that is not locked left pending.
+func (uc *usecase) newPerson(req entity.PersonRequest) (entity.Person, error) {
+	if err := validatePerson(&req); err != nil {
+		log.Err(err).Msg("Failed to validate person")
+		return entity.Person{}, err
+	}
+	params := req.Fields()
+	person := req.Person()
+	for field, value := range params {
+		if field == "locked_attributes" {
+			continue
+		}
+		person.Provenance = append(person.Provenance, entity.Provenance{
+			Field:  field,
+			Value:  fmt.Sprint(value),
+			Source: entity.SourceInput,
+		})
+	}
+	if person.CountryID == "" && uc.defaultCountryID != "" {
+		person.CountryID = uc.defaultCountryID
+		person.Provenance = append(person.Provenance, entity.Provenance{
+			Field:  "country_id",
+			Value:  uc.defaultCountryID,
+			Source: entity.SourceDefault,
+		})
+	}
+	if person.LockedAttributes == nil {
+		person.LockedAttributes = uc.manualAttributes(params)
+	}
+	if person.Gender != nil && person.GenderSource == "" {
+		person.GenderSource = entity.SourceInput
+	}
+
+	person.EnrichmentStatus = entity.EnrichmentDone
+	for _, attribute := range uc.enricher.Attributes() {
+		if !person.LockedAttributes.Contains(attribute) {
+			person.PendingAttributes = append(person.PendingAttributes, attribute)
+		}
+	}
+	if len(person.PendingAttributes) != 0 {
+		person.EnrichmentStatus = entity.EnrichmentPending
+	}
+	return person, nil
 }
 
-const (
-	agifyAPI       = "https://api.agify.io/?name=%s"
-	genderizeAPI   = "https://api.genderize.io/?name=%s"
-	nationalizeAPI = "https://api.nationalize.io/?name=%s"
-)
+// ReplacePerson replaces every editable field of a person with req. Omitted
+// optional fields are cleared. A non-zero version must match the current one.
+func (uc *usecase) ReplacePerson(ctx context.Context, id int, version int, req entity.PersonRequest, enrich bool) error {
+	log.Debug().Int("id", id).Int("version", version).Interface("request", req).Bool("enrich", enrich).Msg("Calling ReplacePerson usecase")
 
-func enrichPersonData(person *entity.Person) {
-	log.Debug().Str("name", person.Name).Msg("Enriching person data")
-	if person.Name != "" {
-		person.Age = getAge(person.Name)
-		person.Gender = getGender(person.Name)
-		person.Nationality = getNationality(person.Name)
+	person, err := uc.currentPerson(ctx, id, version)
+	if err != nil {
+		return err
 	}
+	return uc.replacePerson(ctx, person, req, enrich)
 }
 
-func getAge(name string) int {
-	log.Debug().Str("name", name).Msg("Getting age")
+// PatchPerson applies a JSON Merge Patch or a JSON Patch to the editable
+// fields of a person. A non-zero version must match the current one.
+func (uc *usecase) PatchPerson(ctx context.Context, id int, version int, format string, patch []byte, enrich bool) error {
+	log.Debug().Int("id", id).Int("version", version).Str("format", format).Bool("enrich", enrich).Msg("Calling PatchPerson usecase")
 
-	url := fmt.Sprintf(agifyAPI, name)
-	resp, err := http.Get(url)
+	person, err := uc.currentPerson(ctx, id, version)
 	if err != nil {
-		log.Err(err).Msg("Failed to get age")
-		return 0
+		return err
 	}
-	defer func(Body io.ReadCloser) {
-		err := Body.Close()
-		if err != nil {
-			log.Err(err).Msg("Failed to close response body")
+	doc, err := personDocument(person)
+	if err != nil {
+		return err
+	}
+
+	switch format {
+	case entity.MergePatch:
+		var mergePatch interface{}
+		if err := json.Unmarshal(patch, &mergePatch); err != nil {
+			return fmt.Errorf("%w: malformed merge patch: %v", entity.ErrValidation, err)
 		}
-	}(resp.Body)
+		doc = applyMergePatch(doc, mergePatch)
+	case entity.JSONPatch:
+		var operations []patchOperation
+		if err := json.Unmarshal(patch, &operations); err != nil {
+			return fmt.Errorf("%w: malformed JSON patch: %v", entity.ErrValidation, err)
+		}
+		if doc, err = applyJSONPatch(doc, operations); err != nil {
+			log.Err(err).Int("id", id).Msg("Failed to apply JSON patch")
+			return err
+		}
+	default:
+		return fmt.Errorf("%w: unsupported patch format %q", entity.ErrValidation, format)
+	}
 
-	body, err := io.ReadAll(resp.Body)
+	data, err := json.Marshal(doc)
 	if err != nil {
-		log.Err(err).Msg("Failed to read response body")
-		return 0
+		return err
 	}
+	var req entity.PersonRequest
+	if err := entity.DecodePersonRequest(bytes.NewReader(data), &req); err != nil {
+		return err
+	}
+	return uc.replacePerson(ctx, person, req, enrich)
+}
 
-	var ageResponse map[string]interface{}
-	err = json.Unmarshal(body, &ageResponse)
+// currentPerson loads a person and checks that it is at version unless
+// version is zero.
+func (uc *usecase) currentPerson(ctx context.Context, id int, version int) (entity.Person, error) {
+	person, err := uc.repo.GetPersonByID(ctx, id)
 	if err != nil {
-		log.Err(err).Msg("Failed to unmarshal age response")
-		return 0
+		return entity.Person{}, err
 	}
+	if version != 0 && person.Version != version {
+		return entity.Person{}, fmt.Errorf("person %d is not at version %d: %w", id, version, entity.ErrPreconditionFailed)
+	}
+	return person, nil
+}
 
-	age, ok := ageResponse["age"].(float64)
-	if !ok {
-		log.Err(err).Interface("ageResponse", ageResponse).Msg("Failed to get age from response")
-		return 0
+// replacePerson validates req as a complete person and saves the fields that
+// differ from person.
+func (uc *usecase) replacePerson(ctx context.Context, person entity.Person, req entity.PersonRequest, enrich bool) error {
+	if err := validatePerson(&req); err != nil {
+		log.Err(err).Msg("Failed to validate person")
+		return err
 	}
 
-	return int(age)
-}
+	updates := uc.personChanges(person, req)
+	cleared := uc.lockManualAttributes(person, updates)
 
-func getGender(name string) string {
-	log.Debug().Str("name", name).Msg("Getting gender")
+	if _, renamed := updates["name"]; (renamed && enrich) || len(cleared) != 0 {
+		return uc.updateAndEnrichPerson(ctx, person, updates, cleared, enrich)
+	}
 
-	url := fmt.Sprintf(genderizeAPI, name)
-	resp, err := http.Get(url)
+	err := uc.repo.UpdatePerson(ctx, person.ID, person.Version, updates)
 	if err != nil {
-		log.Err(err).Msg("Failed to get gender")
-		return ""
+		log.Err(err).Msg("Failed to update person")
+		return err
 	}
-	defer func(Body io.ReadCloser) {
-		err := Body.Close()
-		if err != nil {
-			log.Err(err).Msg("Failed to close response body")
+
+	return nil
+}
+
+// personChanges returns the columns whose values in req differ from person.
+// A nil value clears the column.
+func (uc *usecase) personChanges(person entity.Person, req entity.PersonRequest) map[string]interface{} {
+	fields := req.Fields()
+	if _, ok := fields["country_id"]; !ok {
+		fields["country_id"] = uc.defaultCountryID
+	}
+
+	updates := make(map[string]interface{})
+	for field, current := range map[string]string{
+		"name":       person.Name,
+		"surname":    person.Surname,
+		"patronymic": person.Patronymic,
+		"country_id": person.CountryID,
+	} {
+		value, _ := fields[field].(string)
+		if value != current {
+			updates[field] = value
 		}
-	}(resp.Body)
+	}
 
-	body, err := io.ReadAll(resp.Body)
-	if err != nil {
-		log.Err(err).Msg("Failed to read response body")
-		return ""
+	if !reflect.DeepEqual(req.Age, person.Age) {
+		updates["age"] = fields["age"]
+	}
+	if !reflect.DeepEqual(req.Gender, person.Gender) {
+		updates["gender"] = fields["gender"]
+	}
+	if !reflect.DeepEqual(req.Nationality, person.Nationality) {
+		updates["nationality"] = fields["nationality"]
 	}
 
-	var genderResponse map[string]interface{}
-	err = json.Unmarshal(body, &genderResponse)
+	if locked, ok := fields["locked_attributes"].(entity.Attributes); ok && !sameAttributes(locked, person.LockedAttributes) {
+		updates["locked_attributes"] = locked
+	}
+	return updates
+}
+
+// personDocument returns the editable fields of person as a generic JSON
+// document for patching.
+func personDocument(person entity.Person) (interface{}, error) {
+	locked := []string{}
+	locked = append(locked, person.LockedAttributes...)
+	data, err := json.Marshal(entity.PersonRequest{
+		Name:             &person.Name,
+		Surname:          &person.Surname,
+		Patronymic:       &person.Patronymic,
+		Age:              person.Age,
+		Gender:           person.Gender,
+		Nationality:      person.Nationality,
+		CountryID:        &person.CountryID,
+		LockedAttributes: &locked,
+	})
 	if err != nil {
-		log.Err(err).Msg("Failed to unmarshal gender response")
-		return ""
+		return nil, err
 	}
 
-	gender, ok := genderResponse["gender"].(string)
-	if !ok {
-		log.Err(err).Interface("genderResponse", genderResponse).Msg("Failed to get gender from response")
-		return ""
+	var doc interface{}
+	err = json.Unmarshal(data, &doc)
+	return doc, err
+}
+
+func sameAttributes(a, b entity.Attributes) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for _, attribute := range a {
+		if !b.Contains(attribute) {
+			return false
+		}
 	}
+	return true
+}
+
+// DeletePerson deletes a person. A non-zero version must match the current
+// one. With idempotent set deleting a missing person succeeds.
+func (uc *usecase) DeletePerson(ctx context.Context, id int, version int, idempotent bool) error {
+	log.Debug().Int("id", id).Int("version", version).Bool("idempotent", idempotent).Msg("Calling DeletePerson usecase")
 
-	return gender
+	err := uc.repo.DeletePerson(ctx, id, version)
+	if idempotent && errors.Is(err, entity.ErrNotFound) {
+		return nil
+	}
+	return err
 }
 
-func getNationality(name string) string {
-	log.Debug().Str("name", name).Msg("Getting nationality")
+func (uc *usecase) RestorePerson(ctx context.Context, id int) error {
+	log.Debug().Int("id", id).Msg("Calling RestorePerson usecase")
+	return uc.repo.RestorePerson(ctx, id)
+}
+
+// PurgePeople permanently removes people deleted longer than the retention
+// ago.
+func (uc *usecase) PurgePeople(ctx context.Context) (int, error) {
+	log.Debug().Dur("retention", uc.purgeRetention).Msg("Calling PurgePeople usecase")
 
-	url := fmt.Sprintf(nationalizeAPI, name)
-	resp, err := http.Get(url)
+	purged, err := uc.repo.PurgePeople(ctx, time.Now().Add(-uc.purgeRetention))
 	if err != nil {
-		log.Err(err).Msg("Failed to get nationality")
-		return ""
+		return 0, err
 	}
-	defer func(Body io.ReadCloser) {
-		err := Body.Close()
-		if err != nil {
-			log.Err(err).Msg("Failed to close response body")
+	log.Info().Int("purged", purged).Msg("Purged deleted people")
+	return purged, nil
+}
+
+func (uc *usecase) GetEnrichmentStatus(ctx context.Context) []enricher.ProviderStatus {
+	log.Debug().Msg("Calling GetEnrichmentStatus usecase")
+
+	reporter, ok := uc.enricher.(enricher.Reporter)
+	if !ok {
+		return []enricher.ProviderStatus{}
+	}
+	return reporter.Status()
+}
+
+const (
+	maxFieldLength = 255
+	maxAge         = 150
+)
+
+var genders = []string{"male", "female"}
+
+// validatePerson checks req against the Person schema and normalizes country
+// codes. All violations are reported at once.
+func validatePerson(req *entity.PersonRequest) error {
+	log.Debug().Interface("request", req).Msg("Validating person request")
+
+	verr := entity.ValidationError{Violations: append([]entity.Violation(nil), req.DecodeViolations()...)}
+
+	for _, field := range []struct {
+		name     string
+		value    *string
+		required bool
+	}{
+		{"name", req.Name, true},
+		{"surname", req.Surname, true},
+		{"patronymic", req.Patronymic, false},
+	} {
+		switch {
+		case req.Invalid(field.name):
+		case field.value == nil:
+			if field.required {
+				verr.Add(field.name, entity.ViolationRequired, "is required")
+			}
+		case field.required && strings.TrimSpace(*field.value) == "":
+			verr.Add(field.name, entity.ViolationEmpty, "must not be empty")
+		case utf8.RuneCountInString(*field.value) > maxFieldLength:
+			verr.Add(field.name, entity.ViolationTooLong, "must be at most %d characters", maxFieldLength)
 		}
-	}(resp.Body)
+	}
 
-	body, err := io.ReadAll(resp.Body)
-	if err != nil {
-		log.Err(err).Msg("Failed to read response body")
-		return ""
+	if req.Age != nil && (*req.Age < 0 || *req.Age > maxAge) {
+		verr.Add("age", entity.ViolationRange, "must be between 0 and %d", maxAge)
 	}
 
-	var nationalityResponse map[string]interface{}
-	err = json.Unmarshal(body, &nationalityResponse)
-	if err != nil {
-		log.Err(err).Msg("Failed to unmarshal nationality response")
-		return ""
+	if req.Gender != nil && !contains(genders, *req.Gender) {
+		verr.Add("gender", entity.ViolationEnum, "must be one of %s", strings.Join(genders, ", "))
 	}
 
-	countryArray, ok := nationalityResponse["country"].([]interface{})
-	if !ok || len(countryArray) == 0 {
-		log.Err(err).Interface("nationalityResponse", nationalityResponse).Msg("Failed to get nationality from response")
-		return ""
+	if req.Nationality != nil {
+		nationality := strings.ToUpper(*req.Nationality)
+		if !isCountryID(nationality) {
+			verr.Add("nationality", entity.ViolationEnum, "must be an ISO 3166-1 alpha-2 country code")
+		}
+		req.Nationality = &nationality
 	}
 
-	countryInfo := countryArray[0].(map[string]interface{})
-	countryCode, ok := countryInfo["country_id"].(string)
-	if !ok {
-		log.Err(err).Interface("countryInfo", countryInfo).Msg("Failed to get country code from response")
-		return ""
+	if req.CountryID != nil {
+		countryID := strings.ToUpper(*req.CountryID)
+		if !isCountryID(countryID) {
+			verr.Add("country_id", entity.ViolationEnum, "must be an ISO 3166-1 alpha-2 country code")
+		}
+		req.CountryID = &countryID
 	}
 
-	return countryCode
-}
+	if req.LockedAttributes != nil {
+		attributes := []string{enricher.AttributeAge, enricher.AttributeGender, enricher.AttributeNationality}
+		for _, attribute := range *req.LockedAttributes {
+			if !contains(attributes, attribute) {
+				verr.Add("locked_attributes", entity.ViolationEnum, "%q is not one of %s", attribute, strings.Join(attributes, ", "))
+			}
+		}
+	}
 
-func validateFields(data map[string]interface{}) error {
-	log.Debug().Interface("data", data).Msg("Validating fields")
+	return verr.Err()
+}
 
-	allowedFields := map[string]bool{
-		"name":        true,
-		"surname":     true,
-		"patronymic":  true,
-		"age":         true,
-		"gender":      true,
-		"nationality": true,
+// parseTimestamp accepts an RFC 3339 timestamp or a YYYY-MM-DD date in UTC.
+func parseTimestamp(value string) (time.Time, error) {
+	if timestamp, err := time.Parse(time.RFC3339, value); err == nil {
+		return timestamp, nil
 	}
+	return time.Parse(time.DateOnly, value)
+}
 
-	for field := range data {
-		if _, ok := allowedFields[field]; !ok {
-			return fmt.Errorf("invalid field: %s", field)
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
 		}
 	}
-
-	return nil
+	return false
 }
@@ -0,0 +1,122 @@
+package usecase
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"github.com/OksidGen/enrich_server/internal/enricher"
+	"github.com/OksidGen/enrich_server/internal/entity"
+	"github.com/OksidGen/enrich_server/internal/repository"
+	"github.com/rs/zerolog/log"
+	"sync"
+	"time"
+)
+
+type WorkerOptions struct {
+	Workers      int
+	PollInterval time.Duration
+	Lease        time.Duration
+	MaxAttempts  int
+	RetryDelay   time.Duration
+}
+
+// EnrichmentWorker processes enrichment jobs queued by CreatePerson.
+type EnrichmentWorker struct {
+	repo     repository.Repository
+	enricher enricher.Enricher
+	opts     WorkerOptions
+}
+
+func NewEnrichmentWorker(repo repository.Repository, enricher enricher.Enricher, opts WorkerOptions) *EnrichmentWorker {
+	return &EnrichmentWorker{repo, enricher, opts}
+}
+
+// Run blocks until ctx is cancelled and every worker has returned.
+func (w *EnrichmentWorker) Run(ctx context.Context) {
+	log.Info().Int("workers", w.opts.Workers).Msg("Starting enrichment workers")
+
+	var wg sync.WaitGroup
+	for i := 0; i < w.opts.Workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			w.poll(ctx)
+		}()
+	}
+	wg.Wait()
+
+	log.Info().Msg("Enrichment workers stopped")
+}
+
+func (w *EnrichmentWorker) poll(ctx context.Context) {
+	for {
+		job, err := w.repo.ClaimEnrichmentJob(ctx, w.opts.Lease)
+		if err == nil {
+			w.process(ctx, job)
+			continue
+		}
+		if !errors.Is(err, sql.ErrNoRows) && ctx.Err() == nil {
+			log.Err(err).Msg("Failed to claim enrichment job")
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(w.opts.PollInterval):
+		}
+	}
+}
+
+func (w *EnrichmentWorker) process(ctx context.Context, job entity.EnrichmentJob) {
+	ctx = entity.WithActor(ctx, entity.ActorWorker)
+	log.Debug().Int64("job", job.ID).Int("person_id", job.PersonID).Int("attempt", job.Attempts).Msg("Processing enrichment job")
+
+	person, err := w.repo.GetPersonByID(ctx, job.PersonID)
+	if errors.Is(err, entity.ErrNotFound) {
+		log.Warn().Int64("job", job.ID).Int("person_id", job.PersonID).Msg("Dropping enrichment job of a missing person")
+		if err := w.repo.DeleteEnrichmentJob(ctx, job); err != nil {
+			log.Err(err).Int64("job", job.ID).Msg("Failed to drop enrichment job")
+		}
+		return
+	}
+	if err != nil {
+		log.Err(err).Int64("job", job.ID).Msg("Failed to load person for enrichment")
+		return
+	}
+
+	var enrichErr error
+	if len(person.PendingAttributes) != 0 {
+		enrichErr = w.enricher.Enrich(ctx, &person)
+		if ctx.Err() != nil {
+			return
+		}
+	}
+
+	switch {
+	case len(person.PendingAttributes) == 0:
+		person.EnrichmentStatus = entity.EnrichmentDone
+	case job.Attempts >= w.opts.MaxAttempts:
+		log.Warn().Err(enrichErr).Int("id", person.ID).Strs("pending", person.PendingAttributes).Msg("Giving up on enrichment")
+		person.EnrichmentStatus = entity.EnrichmentFailed
+	default:
+		delay := w.opts.RetryDelay * time.Duration(job.Attempts)
+		err := w.repo.RescheduleEnrichmentJob(ctx, job, person, delay, enrichErr)
+		w.logSaveError(job, err, "Failed to reschedule enrichment job")
+		return
+	}
+
+	err = w.repo.FinishEnrichmentJob(ctx, job, person)
+	w.logSaveError(job, err, "Failed to finish enrichment job")
+}
+
+// logSaveError reports a failed save of an enrichment result. A person that
+// changed while being enriched is expected: the job is simply retried.
+func (w *EnrichmentWorker) logSaveError(job entity.EnrichmentJob, err error, msg string) {
+	switch {
+	case err == nil:
+	case errors.Is(err, entity.ErrConflict):
+		log.Debug().Err(err).Int64("job", job.ID).Msg("Person changed during enrichment, retrying")
+	default:
+		log.Err(err).Int64("job", job.ID).Msg(msg)
+	}
+}